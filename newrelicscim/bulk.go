@@ -0,0 +1,153 @@
+package newrelicscim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const bulkPath = "Bulk"
+
+// ErrBulkNotSupported is returned by Bulk when the service provider configuration does not advertise bulk support, or
+// when the request has more operations than the service provider accepts.
+var ErrBulkNotSupported = errors.New("bulk operations not supported")
+
+// BulkOperation represents a single operation in a SCIM bulk request.
+//
+// It has the following fields:
+//  - Method: the HTTP method of the operation, such as http.MethodPost, http.MethodPut, http.MethodPatch or http.MethodDelete
+//  - BulkID: a client chosen identifier for a POST operation; other operations can refer to the created resource as
+//    "bulkId:<BulkID>" in their path or data
+//  - Version: an optional ETag the resource must match for the operation to be applied
+//  - Path: the resource path relative to the base URL, such as "/Users" or "/Groups/<id>"
+//  - Data: the request body of the operation, such as a User, a Group or a PatchRequest
+type BulkOperation struct {
+	Method  string      `json:"method"`
+	BulkID  string      `json:"bulkId,omitempty"`
+	Version string      `json:"version,omitempty"`
+	Path    string      `json:"path"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// BulkRequest represents the body of a SCIM bulk request.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the request, defaulting to the BulkRequest message schema
+//  - FailOnErrors: the number of failed operations after which the service provider stops processing, 0 for no limit
+//  - Operations: the operations to perform, in order
+type BulkRequest struct {
+	Schemas      []string        `json:"schemas"`
+	FailOnErrors int             `json:"failOnErrors,omitempty"`
+	Operations   []BulkOperation `json:"Operations"`
+}
+
+// fill_defaults is a helper function that sets the Schemas field of a BulkRequest to the BulkRequest message schema if
+// it is empty.
+func (br *BulkRequest) fill_defaults() {
+
+	// setting default values
+	// if no values present
+	if len(br.Schemas) == 0 {
+		br.Schemas = []string{"urn:ietf:params:scim:api:messages:2.0:BulkRequest"}
+	}
+}
+
+// BulkOperationResponse represents the result of a single operation in a SCIM bulk response.
+//
+// It has the following fields:
+//  - Method: the HTTP method of the operation
+//  - BulkID: the BulkID of the operation, if one was given
+//  - Version: the version of the resource after the operation
+//  - Location: the URI of the created or modified resource
+//  - Status: the HTTP status code of the operation, such as "201"
+//  - Response: the raw response body of the operation, typically a SCIM error message when the operation failed
+type BulkOperationResponse struct {
+	Method   string          `json:"method"`
+	BulkID   string          `json:"bulkId"`
+	Version  string          `json:"version"`
+	Location string          `json:"location"`
+	Status   string          `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// BulkResponse represents the response of a SCIM bulk request.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the response
+//  - Operations: the results of the operations, in the order they were processed
+type BulkResponse struct {
+	Schemas    []string                `json:"schemas"`
+	Operations []BulkOperationResponse `json:"Operations"`
+}
+
+// Bulk sends several create, update and delete operations to the New Relic SCIM API in a single request, as described
+// in RFC 7644 section 3.7.
+//
+// Before the request is sent, the service provider configuration is fetched to check that bulk requests are supported
+// and that the number of operations is within the advertised limit. If not, an error wrapping ErrBulkNotSupported is
+// returned.
+//
+// The request is only retried after 429 Too Many Requests, since a retry after 502, 503 or 504 could apply operations
+// that had already been applied.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - ops: the operations to perform, in order
+//
+// It returns the following values:
+//  - bulkResponse: a BulkResponse struct with the result of every operation if the request was successful
+//  - errorResponse: an ErrorResponse struct containing details of the error if the request was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) Bulk(ctx context.Context, ops []BulkOperation) (bulkResponse BulkResponse, errorResponse ErrorResponse, err error) {
+	config, errorResponse, err := c.GetServiceProviderConfig(ctx)
+	if err != nil || errorResponse.Status != "" {
+		return bulkResponse, errorResponse, err
+	}
+	if !config.Bulk.Supported {
+		return bulkResponse, errorResponse, ErrBulkNotSupported
+	}
+	if config.Bulk.MaxOperations > 0 && len(ops) > config.Bulk.MaxOperations {
+		return bulkResponse, errorResponse, fmt.Errorf("%d operations exceed the maximum of %d: %w", len(ops), config.Bulk.MaxOperations, ErrBulkNotSupported)
+	}
+
+	return c.sendBulk(ctx, ops)
+}
+
+// sendBulk sends the bulk request for Bulk and ImportUsers, without checking the service provider configuration.
+func (c *Client) sendBulk(ctx context.Context, ops []BulkOperation) (bulkResponse BulkResponse, errorResponse ErrorResponse, err error) {
+	fullUrl := c.resourceURL(bulkPath)
+	bulkRequest := BulkRequest{
+		Operations: ops,
+	}
+	bulkRequest.fill_defaults()
+
+	//Encode the data
+	postBody, err := json.Marshal(bulkRequest)
+	if err != nil {
+		return bulkResponse, errorResponse, err
+	}
+	requestBody := bytes.NewBuffer(postBody)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullUrl, requestBody)
+	if err != nil {
+		return bulkResponse, errorResponse, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return bulkResponse, errorResponseOf(err), err
+	}
+	if err := json.Unmarshal(resp, &bulkResponse); err != nil {
+		return bulkResponse, errorResponse, err
+	}
+	if isErrorResponse(bulkResponse.Schemas) {
+		if err := json.Unmarshal(resp, &errorResponse); err != nil {
+			return bulkResponse, errorResponse, err
+		}
+	}
+
+	return bulkResponse, errorResponse, nil
+}
@@ -1,68 +1,654 @@
 package newrelicscim
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Version is the version of this client library. It is part of the default User-Agent header.
+const Version = "0.1.0"
+
+// defaultUserAgent is the User-Agent header sent with every request when no WithUserAgent option is given.
+const defaultUserAgent = "new-relic-scim-go-client/" + Version
+
+// Datacenter identifies a New Relic datacenter region, each of which serves the SCIM API from its own host.
+type Datacenter int
+
+const (
+	DatacenterUS Datacenter = iota
+	DatacenterEU
+)
+
+// BaseURL returns the SCIM API base URL of the datacenter, including the version number.
+func (d Datacenter) BaseURL() string {
+	switch d {
+	case DatacenterEU:
+		return "https://scim-provisioning.service.eu.newrelic.com/scim/v2/"
+	}
+	return "https://scim-provisioning.service.newrelic.com/scim/v2/"
+}
+
+// defaultMaxRetries is the number of times a rate limited or failed request is retried when no WithMaxRetries option is given.
+const defaultMaxRetries = 3
+
+// defaultRetryableStatusCodes are the response status codes that are retried when no WithRetryableStatusCodes option is
+// given.
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// defaultMaxMembersPerPatch is the largest number of users added to or removed from a group with one PATCH request
+// when no WithMaxMembersPerPatch option is given.
+const defaultMaxMembersPerPatch = 100
+
+// defaultImportConcurrency is the number of users ImportUsers creates at the same time when the Bulk endpoint is not
+// available and no WithImportConcurrency option is given.
+const defaultImportConcurrency = 4
+
+// retryBaseDelay caps the random delay before the first retry when the response carries no Retry-After header. The
+// cap doubles on every further attempt, up to retryMaxDelay.
+const retryBaseDelay = 500 * time.Millisecond
+
+// retryMaxDelay is the largest cap of the random retry delay.
+const retryMaxDelay = 30 * time.Second
+
+// scimMediaType is the SCIM media type, sent as the Accept header of every request and as the content type unless
+// changed with WithContentType.
+const scimMediaType = "application/scim+json"
+
+// defaultTimezone is the timezone assigned to users created or updated without one when no WithDefaultTimezone option
+// is given.
+const defaultTimezone = "Etc/UTC"
+
 // Client is a struct for interacting with the New Relic SCIM API.
 //
 // It has the following fields:
 //  - BaseUrl: the base URL for the SCIM API, including the version number
 //  - ApiToken: the API token for authenticating with the SCIM API
 //  - HttpClient: an HTTP client with a timeout of 20 seconds, used for making requests to the SCIM API
+//  - MaxRetries: the number of times a request is retried after a response with a retryable status code
+//  - RetryableStatusCodes: the response status codes that are retried, 429, 502, 503 and 504 if empty
+//  - DefaultTimezone: the timezone assigned to users that are created or updated without one
+//  - DefaultActive: the active flag sent for users that are created without active set
+//  - Logger: an optional Logger that receives request and response diagnostics
+//  - UserAgent: the User-Agent header sent with every request
+//  - RequestTimeout: the deadline applied to a call whose context has none, including retries and reading the body
+//  - ContentType: the content-type header sent with every request
+//  - DryRun: whether requests that change data are logged instead of sent
+//  - MaxMembersPerPatch: the largest number of users changed in one group membership PATCH request
+//  - ImportConcurrency: the number of concurrent create requests ImportUsers sends without the Bulk endpoint
+//
+// A Client is safe for concurrent use by multiple goroutines. Its exported fields must not be changed once requests are
+// being sent; shared state the client updates itself, such as the rate limiter and the last reported rate limit, is
+// guarded by mutexes.
 type Client struct {
-	BaseUrl    string
-	ApiToken   string
-	HttpClient *http.Client
+	BaseUrl              string
+	ApiToken             string
+	HttpClient           *http.Client
+	MaxRetries           int
+	RetryableStatusCodes []int
+	DefaultTimezone      string
+	DefaultActive        bool
+	Logger               Logger
+	UserAgent            string
+	RequestTimeout       time.Duration
+	ContentType          string
+	DryRun               bool
+	MaxMembersPerPatch   int
+	ImportConcurrency    int
+
+	limiter        *rateLimiter
+	requestEditors []RequestEditorFn
+	observer       ObserverFn
+	tracer         Tracer
+	// ownsTransport reports whether the transport of HttpClient was created by the client, and so may be cleaned up
+	// by Close.
+	ownsTransport bool
+
+	// mu guards the mutable state below, which is written by responses of concurrent calls. Any state the client
+	// updates after construction must be added here.
+	mu            sync.Mutex
+	lastRateLimit *RateLimit
+	// rand is the source of the retry backoff jitter. When nil, the global source of math/rand is used; tests set a
+	// seeded source to make the delays deterministic.
+	rand *rand.Rand
+}
+
+// ClientOption configures optional settings of a Client created with NewClient.
+type ClientOption func(*Client)
+
+// WithDatacenter selects the base URL of the given datacenter. Clients use DatacenterUS unless configured otherwise.
+func WithDatacenter(datacenter Datacenter) ClientOption {
+	return func(c *Client) {
+		c.BaseUrl = datacenter.BaseURL()
+	}
+}
+
+// WithBaseURL sets a custom base URL for the SCIM API, including the version number, for endpoints not covered by a
+// Datacenter preset. The URL is normalized to end with exactly one slash, so "https://host/scim/v2" and
+// "https://host/scim/v2/" are equivalent.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.BaseUrl = strings.TrimRight(baseURL, "/") + "/"
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests, for example one configured with a proxy or client
+// certificates. The client replaces the default one entirely, including its 20 second timeout, so callers that want a
+// timeout must set one on the given client.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.HttpClient = httpClient
+		c.ownsTransport = false
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests, such as an *http.Transport with a proxy or custom TLS
+// configuration, or a tracing wrapper. The timeout of the HTTP client is kept.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		h := *c.HttpClient
+		h.Transport = transport
+		c.HttpClient = &h
+		c.ownsTransport = false
+	}
+}
+
+// WithConnectionPool tunes how many idle connections to the API are kept open for reuse and for how long, to avoid
+// opening new connections during high-volume syncs. The default transport keeps only 2 idle connections per host.
+// It applies to a copy of the client's *http.Transport, or of http.DefaultTransport if none is set, so it should come
+// after WithHTTPClient or WithTransport options. A RoundTripper that is not an *http.Transport, such as a tracing
+// wrapper, is left unchanged; configure the transport it wraps instead. An idleConnTimeout of 0 keeps the current
+// timeout.
+func WithConnectionPool(maxIdleConnsPerHost int, idleConnTimeout time.Duration) ClientOption {
+	return func(c *Client) {
+		roundTripper := c.HttpClient.Transport
+		if roundTripper == nil {
+			roundTripper = http.DefaultTransport
+		}
+		transport, ok := roundTripper.(*http.Transport)
+		if !ok {
+			return
+		}
+		transport = transport.Clone()
+		transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		if transport.MaxIdleConns != 0 && transport.MaxIdleConns < maxIdleConnsPerHost {
+			transport.MaxIdleConns = maxIdleConnsPerHost
+		}
+		if idleConnTimeout > 0 {
+			transport.IdleConnTimeout = idleConnTimeout
+		}
+		WithTransport(transport)(c)
+		c.ownsTransport = true
+	}
+}
+
+// WithRequestTimeout sets a deadline for every call whose context has no deadline of its own. Unlike the timeout of the
+// HTTP client, it covers the whole call, including retries, and aborts a response body that stops arriving. Callers
+// that need a different deadline for a single call can pass a context created with context.WithTimeout instead.
+func WithRequestTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.RequestTimeout = timeout
+	}
+}
+
+// WithMaxRetries sets the number of times a request is retried after a response with a retryable status code. A value
+// of 0 disables retries.
+func WithMaxRetries(maxRetries int) ClientOption {
+	return func(c *Client) {
+		c.MaxRetries = maxRetries
+	}
+}
+
+// WithRetryableStatusCodes sets the response status codes that are retried, replacing the default of 429, 502, 503 and
+// 504. POST requests other than CreateUser are only retried after 429 whatever the codes, so that a create that was
+// applied before its response was lost is not sent twice.
+func WithRetryableStatusCodes(statusCodes ...int) ClientOption {
+	return func(c *Client) {
+		c.RetryableStatusCodes = statusCodes
+	}
+}
+
+// WithMaxMembersPerPatch sets the largest number of users that AddUsersToGroup, RemoveUsersFromGroup and
+// SyncGroupMembers change with one PATCH request, replacing the default of 100. Larger lists are split into several
+// requests. A value of 0 or less sends every list in one request.
+func WithMaxMembersPerPatch(maxMembers int) ClientOption {
+	return func(c *Client) {
+		c.MaxMembersPerPatch = maxMembers
+	}
+}
+
+// WithImportConcurrency sets the number of users ImportUsers creates at the same time when it falls back to one request
+// per user, replacing the default of 4. A value of 0 or less creates the users one at a time. Requests still wait for
+// the limit set with WithRateLimit, so a higher concurrency does not send faster than the configured rate.
+func WithImportConcurrency(concurrency int) ClientOption {
+	return func(c *Client) {
+		c.ImportConcurrency = concurrency
+	}
+}
+
+// WithDefaultActive sets the active flag sent for users that are created without Active set. It defaults to true, so
+// that provisioned users can log in unless they are deactivated on purpose. Updates never send a default, so a user
+// deactivated with DeactivateUser stays deactivated when it is later updated with Active left nil.
+func WithDefaultActive(active bool) ClientOption {
+	return func(c *Client) {
+		c.DefaultActive = active
+	}
+}
+
+// WithDefaultTimezone sets the timezone assigned to users that are created or updated without one. Users with an
+// explicit timezone are sent unchanged.
+func WithDefaultTimezone(timezone string) ClientOption {
+	return func(c *Client) {
+		c.DefaultTimezone = timezone
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request, replacing the default
+// "new-relic-scim-go-client/<version>".
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithUserAgentSuffix appends a product token, such as "terraform-provider-newrelic/3.1", to the User-Agent header,
+// so that traffic can be attributed to both this library and the calling tool. It appends to the default User-Agent,
+// or to the one set by an earlier WithUserAgent option.
+func WithUserAgentSuffix(suffix string) ClientOption {
+	return func(c *Client) {
+		c.UserAgent = strings.TrimSpace(c.UserAgent + " " + suffix)
+	}
+}
+
+// WithContentType sets the content-type header sent with every request, replacing the default SCIM media type
+// "application/scim+json", such as "application/json" for servers that do not accept the SCIM media type.
+func WithContentType(contentType string) ClientOption {
+	return func(c *Client) {
+		c.ContentType = contentType
+	}
+}
+
+// apiTokenKey is the context key of the API token set with ContextWithAPIToken.
+type apiTokenKey struct{}
+
+// ContextWithAPIToken returns a copy of ctx that makes calls made with it authenticate with apiToken instead of the
+// ApiToken of the client.
+//
+// New Relic scopes the SCIM API by authentication domain through the API token: every authentication domain has its
+// own token, shown when SCIM is enabled for the domain, and there is no header or path segment that selects a domain.
+// To manage several authentication domains with one Client, pass the token of the target domain per call:
+//
+//	ctx := newrelicscim.ContextWithAPIToken(ctx, domainTokens["engineering"])
+//	users, userErrorResponse, err := client.UserList(ctx)
+func ContextWithAPIToken(ctx context.Context, apiToken string) context.Context {
+	return context.WithValue(ctx, apiTokenKey{}, apiToken)
+}
+
+// RequestEditorFn edits a request before it is sent, such as to add a correlation ID or an account header that varies
+// per call. The context is the context of the call. Returning an error aborts the call with that error.
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// WithRequestEditorFn adds a function that edits every request after the default Authorization, Accept, content-type
+// and User-Agent headers are set, so it can override them. Editors run in the order they are added, once per call
+// rather than once per retry.
+func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
+	return func(c *Client) {
+		c.requestEditors = append(c.requestEditors, fn)
+	}
+}
+
+// ObserverFn receives the outcome of every request attempt: the method and URL path, the response status code, or 0 if
+// no response was received, the time the attempt took and whether it was a retry of an earlier attempt.
+type ObserverFn func(method, path string, status int, duration time.Duration, retry bool)
+
+// WithObserver sets a function that is called after every request attempt completes, including failed attempts and
+// retries, for recording latency and status metrics. It is called from the goroutine of the call and must be safe for
+// concurrent use.
+func WithObserver(observer ObserverFn) ClientOption {
+	return func(c *Client) {
+		c.observer = observer
+	}
 }
 
 // NewClient generates a new NewRelicSCIMClient for interacting with the New Relic SCIM API.
 //
 // It takes in an API token for authentication and returns a pointer to a new Client struct. The Client struct
 // contains the following fields:
-//  - BaseUrl: the base URL for the SCIM API, including the version number
+//  - BaseUrl: the base URL for the SCIM API, the US datacenter unless changed with WithDatacenter or WithBaseURL
 //  - ApiToken: the API token for authenticating with the SCIM API
-//  - HttpClient: an HTTP client with a timeout of 20 seconds, used for making requests to the SCIM API
+//  - HttpClient: an HTTP client with a timeout of 20 seconds unless replaced with WithHTTPClient or WithTransport
+//  - MaxRetries: the number of retries after a retryable response, 3 unless changed with WithMaxRetries
+//  - RetryableStatusCodes: 429, 502, 503 and 504 unless changed with WithRetryableStatusCodes
+//  - DefaultTimezone: the timezone for users without one, "Etc/UTC" unless changed with WithDefaultTimezone
+//  - DefaultActive: true unless changed with WithDefaultActive
+//  - UserAgent: "new-relic-scim-go-client/<version>" unless changed with WithUserAgent or WithUserAgentSuffix
+//  - ContentType: "application/scim+json" unless changed with WithContentType
+//  - MaxMembersPerPatch: 100 unless changed with WithMaxMembersPerPatch
+//  - ImportConcurrency: 4 unless changed with WithImportConcurrency
 //
 // The client can be used to make requests to the SCIM API, such as retrieving or updating user information.
-func NewClient(apiToken string) *Client {
+func NewClient(apiToken string, opts ...ClientOption) *Client {
 	h := &http.Client{
-		Timeout: 20 * time.Second,
+		Timeout:   20 * time.Second,
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
 	}
 
-	return &Client{
-		BaseUrl:    "https://scim-provisioning.service.newrelic.com/scim/v2/",
-		ApiToken:   apiToken,
-		HttpClient: h,
+	c := &Client{
+		BaseUrl:            DatacenterUS.BaseURL(),
+		ApiToken:           apiToken,
+		HttpClient:         h,
+		MaxRetries:         defaultMaxRetries,
+		DefaultTimezone:    defaultTimezone,
+		DefaultActive:      true,
+		UserAgent:          defaultUserAgent,
+		ContentType:        scimMediaType,
+		MaxMembersPerPatch: defaultMaxMembersPerPatch,
+		ImportConcurrency:  defaultImportConcurrency,
+		ownsTransport:      true,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
+}
+
+// Close closes the idle connections of the transport the client created, so that a long-lived program that discards
+// clients, such as one per tenant, does not keep their connections open. Transports passed in with WithHTTPClient or
+// WithTransport are not touched, since they may be shared. In-flight requests are not affected, and the client can
+// still be used afterwards. It always returns nil.
+func (c *Client) Close() error {
+	if c.ownsTransport {
+		c.HttpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// VerifyToken checks that the API token is accepted, so a bad token can be detected before a provisioning job starts
+// instead of when its first change fails. It lists a single user, which has no side effects, and returns nil if the
+// request succeeds or an error wrapping ErrUnauthorized if the token is rejected.
+func (c *Client) VerifyToken(ctx context.Context) error {
+	_, err := Result(c.CountUsers(ctx))
+	return err
 }
 
 // doRequest is a helper function that sends an HTTP request and returns the response body as a slice of bytes.
 //
 // It takes in a pointer to an HTTP request and adds the necessary headers for authenticating with the New Relic SCIM API
 // using the client's API token. The function then makes the request and reads the response body into a slice of bytes.
-// If the request or response encounters an error or the response status code is not in the 2xx range, an error is returned.
+// When a RequestTimeout is set and the request context has no deadline, the whole call runs under that timeout.
+// When a rate limit is configured with WithRateLimit, every attempt first waits for its turn.
+// Responses with one of the RetryableStatusCodes are retried up to MaxRetries times, waiting for the duration given in the
+// Retry-After header or, when it is absent, a random delay below an exponentially growing cap. Waiting stops early if
+// the request context is done.
+// If the request or response encounters an error, that error is returned. If the response status code is not in the 2xx
+// range, an *APIError carrying the status code and body is returned. A successful response whose body is not a SCIM
+// message fails with an error wrapping ErrUnexpectedResponse, except for DELETE requests, whose body is never decoded.
+// POST requests are only retried after a 429 Too Many Requests, which means the request was not processed, since a
+// create whose response was lost to a 502 or 504 may have been applied and would be created twice, such as a group,
+// whose displayName need not be unique. Creates that recover from a duplicate mark their context with
+// withRetryableCreate and are retried like any other request; the *APIError of a 409 Conflict answering such a retry
+// has Retried set.
 // Otherwise, the response body is returned as a slice of bytes.
 func (c *Client) doRequest(req *http.Request) ([]byte, error) {
-	req.Header.Set("Authorization", "Bearer "+c.ApiToken)
-	req.Header.Set("content-type", "application/json")
+	raw, err := c.doRequestRaw(req)
+	return raw.Body, err
+}
+
+// doRequestWithHeader works like doRequest, but also returns the headers of the successful response, for callers that
+// need values such as the ETag.
+func (c *Client) doRequestWithHeader(req *http.Request) ([]byte, http.Header, error) {
+	raw, err := c.doRequestRaw(req)
+	return raw.Body, raw.Header, err
+}
+
+// resourceURL builds the URL of an endpoint, such as "Users", or of a single resource when an ID is given. IDs are
+// escaped with url.PathEscape, so an ID containing a slash, a space or a query character stays one path segment. The
+// base URL is joined with exactly one slash, even if BaseUrl was set without a trailing one.
+func (c *Client) resourceURL(endpoint string, id ...string) string {
+	fullUrl := strings.TrimRight(c.BaseUrl, "/") + "/" + endpoint
+	for _, segment := range id {
+		fullUrl += "/" + url.PathEscape(segment)
+	}
+	return fullUrl
+}
+
+// doDelete sends a DELETE request to the given URL. The response body is discarded without being decoded, so a 200
+// with a body and a 204 No Content with an empty body are both treated as success.
+func (c *Client) doDelete(ctx context.Context, fullUrl string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fullUrl, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.doRequest(req)
+	return err
+}
+
+// RawResponse holds the undecoded response of a request, for debugging responses that do not decode as expected.
+//
+// It has the following fields:
+//  - StatusCode: the HTTP status code of the response
+//  - Header: the response headers
+//  - Body: the raw response body
+type RawResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// doRequestRaw works like doRequest, but returns the status code, headers and body of the successful response.
+func (c *Client) doRequestRaw(req *http.Request) (raw RawResponse, err error) {
+	if _, ok := req.Context().Deadline(); !ok && c.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), c.RequestTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	req, endSpan := c.startSpan(req)
+	defer func() { endSpan(raw, err) }()
+	apiToken := c.ApiToken
+	if token, ok := req.Context().Value(apiTokenKey{}).(string); ok && token != "" {
+		apiToken = token
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	req.Header.Set("Accept", scimMediaType)
+	if c.ContentType != "" {
+		req.Header.Set("content-type", c.ContentType)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	for _, edit := range c.requestEditors {
+		if err := edit(req.Context(), req); err != nil {
+			return RawResponse{}, err
+		}
+	}
+	if c.DryRun && req.Method != http.MethodGet {
+		return c.dryRunResponse(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.wait(req.Context()); err != nil {
+				return RawResponse{}, err
+			}
+		}
+		start := time.Now()
+		raw, err := c.send(req)
+		var apiErr *APIError
+		if c.observer != nil {
+			status := raw.StatusCode
+			if errors.As(err, &apiErr) {
+				status = apiErr.StatusCode
+			}
+			c.observer(req.Method, req.URL.Path, status, time.Since(start), attempt > 0)
+		}
+		if err == nil {
+			if req.Method != http.MethodDelete {
+				if err := checkSchemas(raw); err != nil {
+					return RawResponse{}, err
+				}
+			}
+			return raw, nil
+		}
+		if attempt > 0 && errors.As(err, &apiErr) {
+			apiErr.Retried = true
+		}
+
+		if attempt >= c.MaxRetries || !errors.As(err, &apiErr) || !c.isRetryable(req, apiErr.StatusCode) {
+			return RawResponse{}, err
+		}
+
+		wait := retryAfter(apiErr.Header)
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return RawResponse{}, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return RawResponse{}, err
+			}
+		}
+	}
+}
 
+// send performs a single HTTP round trip for doRequest and returns the response, or an *APIError if the response status
+// code is not in the 2xx range.
+func (c *Client) send(req *http.Request) (RawResponse, error) {
+	c.debugf("scim request: %s %s headers: %v", req.Method, req.URL, redactHeader(req.Header))
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return nil, err
+		c.errorf("scim request failed: %s %s: %v", req.Method, req.URL, err)
+		return RawResponse{}, err
 	}
+	c.debugf("scim response: %s %s status: %d", req.Method, req.URL, resp.StatusCode)
+	c.recordRateLimit(resp.Header)
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	// Drain what is left of the body before closing it, on every return path, so the connection can be reused.
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return RawResponse{}, err
 	}
 	if !((resp.StatusCode >= 200) && (resp.StatusCode <= 299)) {
-		return nil, fmt.Errorf("error body: %s\nstatus Code: %d", body, resp.StatusCode)
+		return RawResponse{}, newAPIError(resp.StatusCode, resp.Header, body)
 	}
 
-	return body, nil
+	return RawResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// backoff returns the delay before the given retry attempt when the response carries no Retry-After header. It uses
+// full jitter: a random delay between 0 and an exponentially growing cap, so that clients rate limited at the same
+// time do not retry in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := retryMaxDelay
+	if attempt < 16 && retryBaseDelay<<attempt < ceiling {
+		ceiling = retryBaseDelay << attempt
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rand != nil {
+		return time.Duration(c.rand.Int63n(int64(ceiling)))
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// checkSchemas returns an error wrapping ErrUnexpectedResponse if a successful response has a body that is not a
+// JSON object with a schemas attribute, as every SCIM message has. Without it, such a body would decode into a zero
+// value that cannot be told apart from an empty result. Responses without a body, such as 204 No Content, pass.
+func checkSchemas(raw RawResponse) error {
+	body := bytes.TrimSpace(raw.Body)
+	if len(body) == 0 {
+		return nil
+	}
+	var message struct {
+		Schemas []string `json:"schemas"`
+	}
+	if err := json.Unmarshal(body, &message); err == nil && len(message.Schemas) > 0 {
+		return nil
+	}
+	if len(body) > 100 {
+		body = append(body[:100:100], "..."...)
+	}
+	return fmt.Errorf("%w: missing schemas (status %d, content type %q): %s", ErrUnexpectedResponse, raw.StatusCode, raw.Header.Get("Content-Type"), body)
+}
+
+// retryableCreateKey is the context key set with withRetryableCreate.
+type retryableCreateKey struct{}
+
+// withRetryableCreate returns a copy of ctx that lets a POST made with it be retried on every retryable status code,
+// for creates that recover when a retry conflicts with an earlier attempt that was applied, as CreateUser does.
+func withRetryableCreate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryableCreateKey{}, true)
+}
+
+// isRetryable reports whether req should be retried after a response with the given status code. POST requests are
+// only retried after 429, unless their context was marked with withRetryableCreate.
+func (c *Client) isRetryable(req *http.Request, statusCode int) bool {
+	if !c.isRetryableStatus(statusCode) {
+		return false
+	}
+	if req.Method != http.MethodPost || statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	retryable, _ := req.Context().Value(retryableCreateKey{}).(bool)
+	return retryable
+}
+
+// isRetryableStatus reports whether a response with the given status code should be retried.
+func (c *Client) isRetryableStatus(statusCode int) bool {
+	statusCodes := c.RetryableStatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = defaultRetryableStatusCodes
+	}
+	for _, retryable := range statusCodes {
+		if statusCode == retryable {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, which holds either a number of seconds or an HTTP date, and returns the
+// duration to wait. It returns 0 if the header is missing or invalid.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
 }
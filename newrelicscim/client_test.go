@@ -0,0 +1,235 @@
+package newrelicscim
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer answers the first request with status and every later one with 201 and body, counting the requests.
+func flakyServer(status int, body string) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}))
+	return srv, &requests
+}
+
+func TestCreateGroupNotRetriedAfterBadGateway(t *testing.T) {
+	srv, requests := flakyServer(http.StatusBadGateway, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"id":"1"}`)
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	if _, err := Result(c.CreateGroup(context.Background(), "Engineering")); err == nil {
+		t.Error("CreateGroup succeeded, want the 502 error")
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("CreateGroup sent %d requests, want 1", got)
+	}
+}
+
+func TestCreateGroupRetriedAfterTooManyRequests(t *testing.T) {
+	srv, requests := flakyServer(http.StatusTooManyRequests, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"id":"1"}`)
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	if _, err := Result(c.CreateGroup(context.Background(), "Engineering")); err != nil {
+		t.Errorf("CreateGroup: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("CreateGroup sent %d requests, want 2", got)
+	}
+}
+
+func TestCreateUserRetriedAfterBadGateway(t *testing.T) {
+	srv, requests := flakyServer(http.StatusBadGateway, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"1"}`)
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	user := User{UserName: "john.doe@example.com", Emails: []Email{{Value: "john.doe@example.com", Primary: true}}}
+
+	if _, err := Result(c.CreateUser(context.Background(), user)); err != nil {
+		t.Errorf("CreateUser: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("CreateUser sent %d requests, want 2", got)
+	}
+}
+
+func TestCreateUserRecoversRetriedConflict(t *testing.T) {
+	var posts int32
+	var lookup string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			lookup = r.URL.Query().Get("filter")
+			w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":1,` +
+				`"Resources":[{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"user-1","userName":"john.doe@example.com"}]}`))
+			return
+		}
+		if atomic.AddInt32(&posts, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"409","detail":"exists"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	user := User{UserName: "john.doe@example.com", Emails: []Email{{Value: "john.doe@example.com", Primary: true}}}
+
+	created, err := Result(c.CreateUser(context.Background(), user))
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if got := atomic.LoadInt32(&posts); got != 2 {
+		t.Errorf("CreateUser sent %d POST requests, want 2", got)
+	}
+	if want := `userName eq "john.doe@example.com"`; lookup != want {
+		t.Errorf("lookup filter = %q, want %q", lookup, want)
+	}
+	if created.ID != "user-1" || !created.Recovered {
+		t.Errorf("CreateUser = %+v, want the existing user-1 with Recovered set", created)
+	}
+}
+
+func TestDeleteNoContent(t *testing.T) {
+	var method, path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	if err := c.DeleteUser(context.Background(), "user-1"); err != nil {
+		t.Errorf("DeleteUser: %v", err)
+	}
+	if method != http.MethodDelete || path != "/Users/user-1" {
+		t.Errorf("DeleteUser sent %s %s, want DELETE /Users/user-1", method, path)
+	}
+
+	if err := c.DeleteGroup(context.Background(), "group-1"); err != nil {
+		t.Errorf("DeleteGroup: %v", err)
+	}
+	if method != http.MethodDelete || path != "/Groups/group-1" {
+		t.Errorf("DeleteGroup sent %s %s, want DELETE /Groups/group-1", method, path)
+	}
+}
+
+func TestDeleteOKWithBody(t *testing.T) {
+	for _, body := range []string{`{}`, `OK`, `{"detail":"deleted"}`} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		c := NewClient("token", WithBaseURL(srv.URL))
+		if err := c.DeleteUser(context.Background(), "user-1"); err != nil {
+			t.Errorf("DeleteUser answered with 200 %s: %v", body, err)
+		}
+		if err := c.DeleteGroup(context.Background(), "group-1"); err != nil {
+			t.Errorf("DeleteGroup answered with 200 %s: %v", body, err)
+		}
+		srv.Close()
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("WithRequestTimeout", func(t *testing.T) {
+		c := NewClient("token", WithBaseURL(srv.URL), WithRequestTimeout(50*time.Millisecond))
+		if _, err := Result(c.GetUserByID(context.Background(), "user-1")); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("GetUserByID error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("caller deadline", func(t *testing.T) {
+		c := NewClient("token", WithBaseURL(srv.URL), WithRequestTimeout(time.Minute))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := Result(c.GetUserByID(ctx, "user-1")); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("GetUserByID error = %v, want context.DeadlineExceeded", err)
+		}
+	})
+}
+
+func TestBackoffDeterministic(t *testing.T) {
+	c := NewClient("token")
+	c.rand = rand.New(rand.NewSource(1))
+	replay := rand.New(rand.NewSource(1))
+
+	for attempt, ceiling := range []time.Duration{
+		500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second,
+		30 * time.Second, 30 * time.Second, 30 * time.Second,
+	} {
+		want := time.Duration(replay.Int63n(int64(ceiling)))
+		got := c.backoff(attempt)
+		if got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+		if got < 0 || got >= ceiling {
+			t.Errorf("backoff(%d) = %v, want it in [0, %v)", attempt, got, ceiling)
+		}
+	}
+	if got := c.backoff(64); got < 0 || got >= retryMaxDelay {
+		t.Errorf("backoff(64) = %v, want it in [0, %v)", got, retryMaxDelay)
+	}
+}
+
+// TestClientConcurrentUse shares one client between goroutines that read the last rate limit while their requests are
+// rate limited, answered with rate limit headers and retried with the seeded backoff. Run it with -race to check that
+// the shared state of the client is guarded.
+func TestClientConcurrentUse(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		w.Header().Set("X-RateLimit-Limit", "1000")
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(1000-n, 10))
+		if n%3 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"user-1"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL), WithMaxRetries(10), WithRateLimit(1000, 10))
+	c.rand = rand.New(rand.NewSource(1))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Result(c.GetUserByID(context.Background(), "user-1")); err != nil {
+				errs <- err
+			}
+			c.LastRateLimit()
+			c.backoff(1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("GetUserByID: %v", err)
+	}
+	if rateLimit, ok := c.LastRateLimit(); !ok || rateLimit.Limit != 1000 {
+		t.Errorf("LastRateLimit() = %+v, %t, want the limit of 1000", rateLimit, ok)
+	}
+}
@@ -0,0 +1,234 @@
+package newrelicscim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	serviceProviderConfigPath = "ServiceProviderConfig"
+	resourceTypesPath         = "ResourceTypes"
+	schemasPath               = "Schemas"
+)
+
+// FeatureSupport reports whether the SCIM service provider supports an optional feature.
+type FeatureSupport struct {
+	Supported bool `json:"supported"`
+}
+
+// ServiceProviderConfig represents the configuration the New Relic SCIM API advertises at /ServiceProviderConfig.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the configuration
+//  - DocumentationURI: a link to the service provider's documentation
+//  - Patch: whether PATCH requests are supported
+//  - Bulk: whether bulk requests are supported, with the maximum number of operations and payload size
+//  - Filter: whether filtering is supported, with the maximum number of results a filtered list returns
+//  - ChangePassword: whether password changes are supported
+//  - Sort: whether sorting is supported
+//  - Etag: whether ETags are supported
+//  - AuthenticationSchemes: the authentication schemes the service provider accepts
+//  - Meta: metadata about the configuration resource
+type ServiceProviderConfig struct {
+	Schemas          []string       `json:"schemas"`
+	DocumentationURI string         `json:"documentationUri"`
+	Patch            FeatureSupport `json:"patch"`
+	Bulk             struct {
+		Supported      bool `json:"supported"`
+		MaxOperations  int  `json:"maxOperations"`
+		MaxPayloadSize int  `json:"maxPayloadSize"`
+	} `json:"bulk"`
+	Filter struct {
+		Supported  bool `json:"supported"`
+		MaxResults int  `json:"maxResults"`
+	} `json:"filter"`
+	ChangePassword        FeatureSupport `json:"changePassword"`
+	Sort                  FeatureSupport `json:"sort"`
+	Etag                  FeatureSupport `json:"etag"`
+	AuthenticationSchemes []struct {
+		Type             string `json:"type"`
+		Name             string `json:"name"`
+		Description      string `json:"description"`
+		SpecURI          string `json:"specUri"`
+		DocumentationURI string `json:"documentationUri"`
+		Primary          bool   `json:"primary"`
+	} `json:"authenticationSchemes"`
+	Meta Meta `json:"meta"`
+}
+
+// GetServiceProviderConfig retrieves the features supported by the New Relic SCIM API, such as patch, bulk and
+// filtering, together with their limits.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//
+// It returns the following values:
+//  - serviceProviderConfig: a ServiceProviderConfig struct describing the supported features if the request was successful
+//  - errorResponse: an ErrorResponse struct containing details of the error if the request was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) GetServiceProviderConfig(ctx context.Context) (serviceProviderConfig ServiceProviderConfig, errorResponse ErrorResponse, err error) {
+	fullUrl := c.resourceURL(serviceProviderConfigPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+	if err != nil {
+		return serviceProviderConfig, errorResponse, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return serviceProviderConfig, errorResponseOf(err), err
+	}
+	if err := json.Unmarshal(resp, &serviceProviderConfig); err != nil {
+		return serviceProviderConfig, errorResponse, err
+	}
+	if isErrorResponse(serviceProviderConfig.Schemas) {
+		if err := json.Unmarshal(resp, &errorResponse); err != nil {
+			return serviceProviderConfig, errorResponse, err
+		}
+	}
+
+	return serviceProviderConfig, errorResponse, nil
+}
+
+// ResourceType represents a resource type advertised by the New Relic SCIM API, such as User or Group.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the resource type definition
+//  - ID: the identifier of the resource type
+//  - Name: the name of the resource type
+//  - Endpoint: the path of the resource type endpoint, relative to the base URL
+//  - Description: a human readable description of the resource type
+//  - Schema: the URI of the resource type's core schema
+//  - SchemaExtensions: the extension schemas available for the resource type and whether they are required
+//  - Meta: metadata about the resource type
+type ResourceType struct {
+	Schemas          []string `json:"schemas"`
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Endpoint         string   `json:"endpoint"`
+	Description      string   `json:"description"`
+	Schema           string   `json:"schema"`
+	SchemaExtensions []struct {
+		Schema   string `json:"schema"`
+		Required bool   `json:"required"`
+	} `json:"schemaExtensions"`
+	Meta Meta `json:"meta"`
+}
+
+// ResourceTypesResponse represents the list of resource types returned by GetResourceTypes.
+type ResourceTypesResponse struct {
+	TotalResults int            `json:"totalResults"`
+	Schemas      []string       `json:"schemas"`
+	Resources    []ResourceType `json:"Resources"`
+}
+
+// Schema represents a schema advertised by the New Relic SCIM API, such as the core User schema or the New Relic user
+// extension.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the schema definition
+//  - ID: the URI of the schema
+//  - Name: the name of the schema
+//  - Description: a human readable description of the schema
+//  - Attributes: the attribute definitions of the schema
+//  - Meta: metadata about the schema
+type Schema struct {
+	Schemas     []string          `json:"schemas"`
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  []SchemaAttribute `json:"attributes"`
+	Meta        Meta              `json:"meta"`
+}
+
+// SchemaAttribute represents the definition of a single attribute in a Schema. Complex attributes describe their
+// sub-attributes in SubAttributes.
+type SchemaAttribute struct {
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	MultiValued     bool              `json:"multiValued"`
+	Description     string            `json:"description"`
+	Required        bool              `json:"required"`
+	CanonicalValues []string          `json:"canonicalValues"`
+	CaseExact       bool              `json:"caseExact"`
+	Mutability      string            `json:"mutability"`
+	Returned        string            `json:"returned"`
+	Uniqueness      string            `json:"uniqueness"`
+	ReferenceTypes  []string          `json:"referenceTypes"`
+	SubAttributes   []SchemaAttribute `json:"subAttributes"`
+}
+
+// SchemasResponse represents the list of schemas returned by GetSchemas.
+type SchemasResponse struct {
+	TotalResults int      `json:"totalResults"`
+	Schemas      []string `json:"schemas"`
+	Resources    []Schema `json:"Resources"`
+}
+
+// GetResourceTypes retrieves the resource types supported by the New Relic SCIM API, including the extension schemas
+// available for each of them.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//
+// It returns the following values:
+//  - resourceTypesResponse: a ResourceTypesResponse struct listing the resource types if the request was successful
+//  - errorResponse: an ErrorResponse struct containing details of the error if the request was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) GetResourceTypes(ctx context.Context) (resourceTypesResponse ResourceTypesResponse, errorResponse ErrorResponse, err error) {
+	fullUrl := c.resourceURL(resourceTypesPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+	if err != nil {
+		return resourceTypesResponse, errorResponse, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return resourceTypesResponse, errorResponseOf(err), err
+	}
+	if err := json.Unmarshal(resp, &resourceTypesResponse); err != nil {
+		return resourceTypesResponse, errorResponse, err
+	}
+	if isErrorResponse(resourceTypesResponse.Schemas) {
+		if err := json.Unmarshal(resp, &errorResponse); err != nil {
+			return resourceTypesResponse, errorResponse, err
+		}
+	}
+
+	return resourceTypesResponse, errorResponse, nil
+}
+
+// GetSchemas retrieves the schemas supported by the New Relic SCIM API together with their attribute definitions.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//
+// It returns the following values:
+//  - schemasResponse: a SchemasResponse struct listing the schemas if the request was successful
+//  - errorResponse: an ErrorResponse struct containing details of the error if the request was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) GetSchemas(ctx context.Context) (schemasResponse SchemasResponse, errorResponse ErrorResponse, err error) {
+	fullUrl := c.resourceURL(schemasPath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+	if err != nil {
+		return schemasResponse, errorResponse, err
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return schemasResponse, errorResponseOf(err), err
+	}
+	if err := json.Unmarshal(resp, &schemasResponse); err != nil {
+		return schemasResponse, errorResponse, err
+	}
+	if isErrorResponse(schemasResponse.Schemas) {
+		if err := json.Unmarshal(resp, &errorResponse); err != nil {
+			return schemasResponse, errorResponse, err
+		}
+	}
+
+	return schemasResponse, errorResponse, nil
+}
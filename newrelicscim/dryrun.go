@@ -0,0 +1,47 @@
+package newrelicscim
+
+import (
+	"io"
+	"net/http"
+)
+
+// WithDryRun makes the client log the requests that would change data, instead of sending them, so the changes a
+// program intends to make can be reviewed. GET requests are still sent, so lookups such as GetUserByName keep working.
+//
+// Each skipped request is reported to the Logger, set with WithLogger, with its method, URL and body, and answered with
+// a synthetic success response:
+//  - POST: 201 Created with the request body echoed back, so the created resource decodes without an ID
+//  - PUT and PATCH: 200 OK with the request body echoed back
+//  - DELETE: 204 No Content with an empty body
+func WithDryRun() ClientOption {
+	return func(c *Client) {
+		c.DryRun = true
+	}
+}
+
+// dryRunResponse logs a request that is skipped in dry-run mode and returns the synthetic response described by
+// WithDryRun.
+func (c *Client) dryRunResponse(req *http.Request) (RawResponse, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return RawResponse{}, err
+		}
+		req.Body.Close()
+	}
+	c.debugf("scim dry run: %s %s body: %s", req.Method, req.URL, body)
+
+	raw := RawResponse{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}
+	switch req.Method {
+	case http.MethodPost:
+		raw.StatusCode = http.StatusCreated
+	case http.MethodDelete:
+		raw.StatusCode = http.StatusNoContent
+		raw.Body = nil
+	}
+	if len(raw.Body) > 0 {
+		raw.Header.Set("Content-Type", req.Header.Get("content-type"))
+	}
+	return raw, nil
+}
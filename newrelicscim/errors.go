@@ -0,0 +1,284 @@
+package newrelicscim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Sentinel errors for the API status codes callers most often need to branch on. An *APIError returned by a client
+// method unwraps to one of them, so they can be detected with errors.Is:
+//   - 401 Unauthorized and 403 Forbidden: ErrUnauthorized
+//   - 404 Not Found: ErrNotFound
+//   - 409 Conflict: ErrConflict
+//  - 412 Precondition Failed: ErrPreconditionFailed
+var (
+	// ErrNotFound is returned, usually wrapped, when the API responds with 404 or when a lookup matches no resource.
+	ErrNotFound = errors.New("resource not found")
+	// ErrConflict is returned, wrapped in an *APIError, when the API responds with 409, such as for a duplicate userName.
+	ErrConflict = errors.New("resource conflict")
+	// ErrPreconditionFailed is returned, wrapped in an *APIError, when the API responds with 412 because the ETag of a
+	// conditional request no longer matches the resource.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized is returned, wrapped in an *APIError, when the API responds with 401 or 403.
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// ErrUserNotFound is returned, usually wrapped, when a user lookup matches no user. It wraps ErrNotFound.
+var ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+
+// ErrUnexpectedResponse is returned, wrapped, when a successful response is not a SCIM message, such as an HTML page
+// served for a misconfigured base URL.
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// ErrMissingUserName is reported, in a *ValidationError, when a user is created or replaced without a userName, which SCIM requires.
+var ErrMissingUserName = errors.New("missing userName")
+
+// ErrMissingPrimaryEmail is reported, in a *ValidationError, when a user is created or replaced without an email marked primary,
+// which New Relic requires.
+var ErrMissingPrimaryEmail = errors.New("missing primary email")
+
+// ErrMultiplePrimaryEmails is reported, in a *ValidationError, when more than one email of a user is marked primary.
+var ErrMultiplePrimaryEmails = errors.New("more than one primary email")
+
+// ErrInvalidMemberOperation is reported, in a *ValidationError, when GroupMemberOps is asked for an operation other than
+// add or remove, such as replace, which would drop every other member of the group.
+var ErrInvalidMemberOperation = errors.New("member operation must be add or remove")
+
+// ErrMultipleMatches is returned, usually wrapped, when a lookup that expects a single resource matches several.
+var ErrMultipleMatches = errors.New("multiple resources found")
+
+// APIError is returned when the New Relic SCIM API responds with a status code outside the 2xx range.
+//
+// It has the following fields:
+//   - StatusCode: the HTTP status code of the response
+//   - Header: the response headers
+//   - Body: the raw response body
+//   - ScimType: the SCIM error type, if the body is a SCIM error message
+//   - Detail: the error detail, if the body is a SCIM error message
+//  - Retried: whether the response answered a retry, which means an earlier attempt may have been applied already
+type APIError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ScimType   string
+	Detail     string
+	Retried    bool
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("scim api error: status code: %d, scimType: %q, detail: %s", e.StatusCode, e.ScimType, e.Detail)
+	}
+	return fmt.Sprintf("scim api error: status code: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// Unwrap returns the sentinel error matching the status code, or nil if there is none.
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// MemberBatchError is returned when a group membership change split into several PATCH requests fails after some of
+// the requests were applied, so callers know which users were changed and which were not.
+//
+// It has the following fields:
+//  - Applied: the IDs of the users changed by the requests that succeeded
+//  - Failed: the IDs of the users of the failed request and of the requests that were not sent
+//  - Err: the error of the failed request
+type MemberBatchError struct {
+	Applied []string
+	Failed  []string
+	Err     error
+}
+
+// Error implements the error interface.
+func (e *MemberBatchError) Error() string {
+	return fmt.Sprintf("group membership change applied to %d of %d users: %v", len(e.Applied), len(e.Applied)+len(e.Failed), e.Err)
+}
+
+// Unwrap returns the error of the failed request.
+func (e *MemberBatchError) Unwrap() error {
+	return e.Err
+}
+
+// UserExistsError is returned by CreateUserIfNoneMatch when the server refuses the create with 412 Precondition Failed
+// because the user already exists. errors.Is matches it against ErrConflict, like the 409 of a plain duplicate create,
+// and errors.As still finds the *APIError of the response.
+//
+// It has the following fields:
+//  - UserName: the userName of the user that was not created
+//  - Err: the *APIError of the 412 response
+type UserExistsError struct {
+	UserName string
+	Err      *APIError
+}
+
+// Error implements the error interface.
+func (e *UserExistsError) Error() string {
+	return fmt.Sprintf("user %q already exists: %v", e.UserName, e.Err)
+}
+
+// Is reports whether target is ErrConflict.
+func (e *UserExistsError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Unwrap returns the *APIError of the response.
+func (e *UserExistsError) Unwrap() error {
+	return e.Err
+}
+
+// FieldError is a single problem found by client-side validation.
+//
+// It has the following fields:
+//  - Field: the JSON name of the attribute with the problem, such as "userName" or "emails"
+//  - Err: the problem, usually a sentinel such as ErrMissingUserName
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+// Unwrap returns the problem, so errors.Is matches its sentinel.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned before a request is sent when the resource fails client-side validation. It holds every
+// problem found rather than just the first, so they can all be fixed at once. errors.Is reports whether any of the
+// problems matches the target:
+//
+//	if errors.Is(err, newrelicscim.ErrMissingUserName) {
+//		...
+//	}
+type ValidationError struct {
+	Problems []FieldError
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = problem.Error()
+	}
+	return "invalid resource: " + strings.Join(problems, "; ")
+}
+
+// Is reports whether any of the problems matches target.
+func (e *ValidationError) Is(target error) bool {
+	for _, problem := range e.Problems {
+		if errors.Is(problem, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first problem that matches it and reports whether one did. errors.As only walks the problems
+// returned by Unwrap from Go 1.20, so this keeps matching a FieldError working on older toolchains.
+func (e *ValidationError) As(target interface{}) bool {
+	for _, problem := range e.Problems {
+		if errors.As(problem, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the problems as errors, for errors.Is and errors.As on Go 1.20 and later.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, problem := range e.Problems {
+		errs[i] = problem
+	}
+	return errs
+}
+
+// add records a problem with the given field.
+func (e *ValidationError) add(field string, err error) {
+	e.Problems = append(e.Problems, FieldError{Field: field, Err: err})
+}
+
+// errOrNil returns e if it holds any problem and nil otherwise.
+func (e *ValidationError) errOrNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// newAPIError builds an APIError from a response status code, headers and body, parsing the SCIM error fields when the body
+// contains them.
+func newAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+	}
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(body, &errorResponse); err == nil {
+		apiErr.ScimType = errorResponse.ScimType
+		apiErr.Detail = errorResponse.Detail
+	}
+	return apiErr
+}
+
+// errorResponseOf returns the SCIM error message carried by an *APIError in err, so that methods can return it as
+// their error response. The Status field falls back to the HTTP status code when the body does not contain one. For
+// any other error, including nil, it returns a zero ErrorResponse.
+func errorResponseOf(err error) ErrorResponse {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return ErrorResponse{}
+	}
+	var errorResponse ErrorResponse
+	_ = json.Unmarshal(apiErr.Body, &errorResponse)
+	errorResponse.ScimType = apiErr.ScimType
+	errorResponse.Detail = apiErr.Detail
+	if errorResponse.Status == "" {
+		errorResponse.Status = strconv.Itoa(apiErr.StatusCode)
+	}
+	return errorResponse
+}
+
+// ErrorResponse represents a SCIM error message returned by endpoints that are not specific to users or groups, such as
+// the discovery endpoints.
+//
+// It has the following fields:
+//  - Schemas: a slice of strings containing the SCIM schema URIs of the error message
+//  - ScimType: a string indicating the type of error that occurred
+//  - Detail: a string describing the error in more detail
+//  - Status: a string indicating the HTTP status code of the error
+type ErrorResponse struct {
+	Schemas  []string `json:"schemas"`
+	ScimType string   `json:"scimType"`
+	Detail   string   `json:"detail"`
+	Status   string   `json:"status"`
+}
+
+// errorSchema is the SCIM schema URI that identifies an error message in a response body.
+const errorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+// isErrorResponse reports whether the schemas of a decoded response body identify it as a SCIM error message. It is
+// safe to call with an empty or nil slice, which happens when the API returns a body without a schemas array.
+func isErrorResponse(schemas []string) bool {
+	return len(schemas) > 0 && schemas[0] == errorSchema
+}
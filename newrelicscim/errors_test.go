@@ -0,0 +1,34 @@
+package newrelicscim
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestValidationErrorMatchesProblems(t *testing.T) {
+	user := User{Emails: []Email{{Value: "a@example.com", Primary: true}, {Value: "b@example.com", Primary: true}}}
+	err := fmt.Errorf("create user: %w", user.validate())
+
+	for _, target := range []error{ErrMissingUserName, ErrMultiplePrimaryEmails} {
+		if !errors.Is(err, target) {
+			t.Errorf("errors.Is(%v, %v) = false, want true", err, target)
+		}
+	}
+	if errors.Is(err, ErrMissingPrimaryEmail) {
+		t.Errorf("errors.Is(%v, ErrMissingPrimaryEmail) = true, want false", err)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) != 2 {
+		t.Fatalf("errors.As(*ValidationError) = %v, want both problems", validationErr)
+	}
+	var fieldErr FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "userName" {
+		t.Errorf("errors.As(FieldError) = %+v, want the userName problem", fieldErr)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("errors.As(*APIError) = true, want false")
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strconv"
 )
 
 const groupPath = "Groups"
@@ -17,8 +18,9 @@ const groupPath = "Groups"
 //  - Schemas: a slice of strings containing the SCIM schema URIs that define the attributes of the group
 //  - DisplayName: the name of the group, which is used to identify it in the New Relic user interface
 type Group struct {
-	Schemas     []string `json:"schemas"`
-	DisplayName string   `json:"displayName"`
+	Schemas     []string     `json:"schemas"`
+	DisplayName string       `json:"displayName"`
+	Members     []PatchValue `json:"members,omitempty"`
 }
 
 // GroupResponse represents a response from the New Relic SCIM API for a group creation or update request.
@@ -27,18 +29,16 @@ type Group struct {
 //  - Schemas: a slice of strings containing the SCIM schema URIs that define the attributes of the group
 //  - ID: the unique identifier for the group, assigned by the New Relic SCIM API
 //  - DisplayName: the name of the group, which is used to identify it in the New Relic user interface
-//  - Meta: metadata about the group, including the resource type, creation date, and last modification date
-//  - Members: a slice of interfaces representing the members of the group (typically user resources)
+//  - Meta: metadata about the group, including the resource type, creation date, last modification date, location and version
+//  - Members: a slice of GroupMember structs representing the members of the group (typically user resources)
+//  - ETag: the version of the group, taken from the ETag response header or Meta.Version
 type GroupResponse struct {
-	Schemas     []string `json:"schemas"`
-	ID          string   `json:"id"`
-	DisplayName string   `json:"displayName"`
-	Meta        struct {
-		ResourceType string    `json:"resourceType"`
-		Created      time.Time `json:"created"`
-		LastModified time.Time `json:"lastModified"`
-	} `json:"meta"`
-	Members []interface{} `json:"members"`
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id"`
+	DisplayName string        `json:"displayName"`
+	Meta        Meta          `json:"meta"`
+	Members     []GroupMember `json:"members"`
+	ETag        string        `json:"-"`
 }
 
 // GroupErrorResponse represents an error response from the New Relic SCIM API for a group creation or update request.
@@ -59,42 +59,69 @@ type GroupErrorResponse struct {
 //
 // It has the following fields:
 //  - TotalResults: an integer indicating the total number of groups that match the list request
+//  - ItemsPerPage: an integer indicating the number of groups returned in this page
+//  - StartIndex: the 1-based index of the first group in this page
 //  - Schemas: a slice of strings containing the SCIM schema URIs that define the attributes of the group list response
 //  - Resources: a slice of structs representing the groups that match the list request, each with the fields described in the GroupResponse struct
+//  - Raw: the undecoded response, for debugging responses that do not decode as expected
 type GroupsResponse struct {
-	TotalResults int      `json:"totalResults"`
-	Schemas      []string `json:"schemas"`
-	Resources    []struct {
-		Schemas     []string `json:"schemas"`
-		ID          string   `json:"id"`
-		DisplayName string   `json:"displayName"`
-		Meta        struct {
-			ResourceType string    `json:"resourceType"`
-			Created      time.Time `json:"created"`
-			LastModified time.Time `json:"lastModified"`
-		} `json:"meta"`
-		Members []struct {
-			Type  string `json:"type"`
-			Value string `json:"value"`
-		} `json:"members"`
-	} `json:"Resources"`
-}
-
-// UpdateGroup represents a request to update a group in the New Relic SCIM API using the patch operation.
+	TotalResults int             `json:"totalResults"`
+	ItemsPerPage int             `json:"itemsPerPage"`
+	StartIndex   int             `json:"startIndex"`
+	Schemas      []string        `json:"schemas"`
+	Resources    []GroupResource `json:"Resources"`
+	Raw          RawResponse     `json:"-"`
+}
+
+// GroupResource represents a single group entry in a GroupsResponse.
+//
+// It has the same fields as the GroupResponse struct, except for the ETag.
+type GroupResource struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id"`
+	DisplayName string        `json:"displayName"`
+	Meta        Meta          `json:"meta"`
+	Members     []GroupMember `json:"members"`
+}
+
+// GroupMember represents a member of a group.
 //
 // It has the following fields:
-//  - Schemas: a slice of strings containing the SCIM schema URIs that define the attributes of the update request
-//  - Operations: a slice of structs representing the patch operations to be performed on the group, such as adding or
-//    removing members or changing the group name
-type UpdateGroup struct {
-	Schemas    []string `json:"schemas"`
-	Operations []struct {
-		Op    string `json:"op"`
-		Path  string `json:"path"`
-		Value []struct {
-			Value string `json:"value"`
-		} `json:"value"`
-	} `json:"Operations"`
+//  - Type: the type of the member resource, such as "User"
+//  - Value: the ID of the member resource
+//  - Display: the display name of the member resource, if the API returns one
+//  - Ref: the URI of the member resource, if the API returns one
+type GroupMember struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// groupResponse converts a GroupResource into a GroupResponse.
+func (gr GroupResource) groupResponse() GroupResponse {
+	return GroupResponse{
+		Schemas:     gr.Schemas,
+		ID:          gr.ID,
+		DisplayName: gr.DisplayName,
+		Meta:        gr.Meta,
+		Members:     gr.Members,
+	}
+}
+
+// UpdateGroup is the body of a group PATCH request.
+//
+// Deprecated: Use PatchRequest, which is the same type.
+type UpdateGroup = PatchRequest
+
+// PatchOp is a single operation of a group PATCH request.
+//
+// Deprecated: Use PatchOperation, which is the same type, or its constructors such as AddOperation.
+type PatchOp = PatchOperation
+
+// PatchValue represents a single member value of a group patch operation, such as the ID of a user to add to a group.
+type PatchValue struct {
+	Value string `json:"value"`
 }
 
 // fill_defaults is a helper function that sets default values for a Group struct if they are not already present.
@@ -111,22 +138,11 @@ func (g *Group) fill_defaults() {
 
 }
 
-// fill_defaults is a helper function that sets default values for an UpdateGroup struct if they are not already present.
-//
-// It sets the Schemas field to the SCIM schema URI for patch operations if it is empty. This is required by the SCIM API
-// when patching a group.
-func (ug *UpdateGroup) fill_defaults() {
-
-	// setting default values
-	// if no values present
-	if len(ug.Schemas) == 0 {
-		ug.Schemas = []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"}
-	}
-
-}
-
 // CreateGroup is a function that creates a new group in the New Relic SCIM API using the provided group name.
 //
+// Group display names need not be unique, so a create that was applied before its response was lost cannot be detected.
+// The request is therefore only retried after 429 Too Many Requests, not after 502, 503 or 504.
+//
 // It takes the following arguments:
 //  - ctx: a context for cancelling or timing out the request
 //  - groupName: the name of the group to be created
@@ -136,29 +152,82 @@ func (ug *UpdateGroup) fill_defaults() {
 //  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
 //  - err: an error value if there was an issue with the request or response
 func (c *Client) CreateGroup(ctx context.Context, groupName string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, groupPath)
-	group := Group{
-		DisplayName: groupName,
+	return c.createGroup(ctx, Group{DisplayName: groupName})
+}
+
+// CreateGroupWithMembersResponse is the result of CreateGroupWithMembers. MembersPatched reports whether the members
+// had to be added with a separate PATCH because the API ignored the members sent with the create request.
+type CreateGroupWithMembersResponse struct {
+	GroupResponse
+	MembersPatched bool
+}
+
+// CreateGroupWithMembers is a function that creates a new group with the given users as its initial members.
+//
+// The members are sent with the create request. If the API creates the group without some of them, they are added with
+// a PATCH request afterwards, and MembersPatched is set in the response so callers can tell which path was taken.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the requests
+//  - displayName: the name of the group to be created
+//  - memberIDs: the IDs of the users to add to the group
+//
+// It returns the following values:
+//  - createResponse: a CreateGroupWithMembersResponse struct containing the details of the created group if the
+//    operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with one of the requests or responses
+func (c *Client) CreateGroupWithMembers(ctx context.Context, displayName string, memberIDs []string) (createResponse CreateGroupWithMembersResponse, groupErrorResponse GroupErrorResponse, err error) {
+	createResponse.GroupResponse, groupErrorResponse, err = c.createGroup(ctx, Group{
+		DisplayName: displayName,
+		Members:     memberValues(memberIDs),
+	})
+	if err != nil || groupErrorResponse.Status != "" {
+		return createResponse, groupErrorResponse, err
+	}
+
+	created := make(map[string]bool, len(createResponse.Members))
+	for _, member := range createResponse.Members {
+		created[member.Value] = true
+	}
+	var missing []string
+	for _, memberID := range memberIDs {
+		if !created[memberID] {
+			missing = append(missing, memberID)
+		}
 	}
+	if len(missing) == 0 {
+		return createResponse, groupErrorResponse, nil
+	}
+
+	createResponse.MembersPatched = true
+	createResponse.GroupResponse, groupErrorResponse, err = c.AddUsersToGroup(ctx, createResponse.ID, missing)
+	return createResponse, groupErrorResponse, err
+}
+
+// createGroup sends a create request for the given group and decodes the response.
+func (c *Client) createGroup(ctx context.Context, group Group) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	fullUrl := c.resourceURL(groupPath)
 	group.fill_defaults()
 
 	//Encode the data
 	postBody, _ := json.Marshal(group)
 	requestBody := bytes.NewBuffer(postBody)
 
-	req, err := http.NewRequest("POST", fullUrl, requestBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fullUrl, requestBody)
 	if err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
 
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return groupResponse, groupErrorResponse, err
+		return groupResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &groupResponse); err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
-	if groupResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	groupResponse.ETag = etag(header, groupResponse.Meta.Version)
+	if isErrorResponse(groupResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupResponse, groupErrorResponse, err
 		}
@@ -172,14 +241,39 @@ func (c *Client) CreateGroup(ctx context.Context, groupName string) (groupRespon
 //
 // It takes the following arguments:
 //  - ctx: a context for cancelling or timing out the request
+//  - groupID: the ID of the group to be updated
 //  - groupName: the new name of the group to be updated
 //
 // It returns the following values:
 //  - groupResponse: a GroupResponse struct containing the details of the updated group if the operation was successful
 //  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
 //  - err: an error value if there was an issue with the request or response
-func (c *Client) UpdateGroup(ctx context.Context, groupName string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, groupPath)
+func (c *Client) UpdateGroup(ctx context.Context, groupID string, groupName string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.updateGroup(ctx, groupID, groupName, "")
+}
+
+// UpdateGroupIfMatch updates a group like UpdateGroup, but only if the group's current version still matches the given
+// ETag, as returned in GroupResponse.ETag.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - groupID: the ID of the group to be updated
+//  - groupName: the new name of the group to be updated
+//  - etag: the version of the group the update is based on
+//
+// It returns the following values:
+//  - groupResponse: a GroupResponse struct containing the details of the updated group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response, wrapping ErrPreconditionFailed if the
+//    group was changed in the meantime
+func (c *Client) UpdateGroupIfMatch(ctx context.Context, groupID string, groupName string, etag string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.updateGroup(ctx, groupID, groupName, etag)
+}
+
+// updateGroup sends the PUT request for UpdateGroup and UpdateGroupIfMatch, setting the If-Match header when ifMatch
+// is not empty.
+func (c *Client) updateGroup(ctx context.Context, groupID string, groupName string, ifMatch string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	fullUrl := c.resourceURL(groupPath, groupID)
 	group := Group{
 		DisplayName: groupName,
 	}
@@ -189,19 +283,23 @@ func (c *Client) UpdateGroup(ctx context.Context, groupName string) (groupRespon
 	postBody, _ := json.Marshal(group)
 	requestBody := bytes.NewBuffer(postBody)
 
-	req, err := http.NewRequest("PUT", fullUrl, requestBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fullUrl, requestBody)
 	if err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
 
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return groupResponse, groupErrorResponse, err
+		return groupResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &groupResponse); err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
-	if groupResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	groupResponse.ETag = etag(header, groupResponse.Meta.Version)
+	if isErrorResponse(groupResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupResponse, groupErrorResponse, err
 		}
@@ -211,38 +309,76 @@ func (c *Client) UpdateGroup(ctx context.Context, groupName string) (groupRespon
 	return groupResponse, groupErrorResponse, nil
 }
 
-// GroupList is a function that retrieves a list of groups from the New Relic SCIM API.
+// GroupList is a function that retrieves the first page of groups from the New Relic SCIM API using the API's default
+// page size.
 //
 // It takes the following arguments:
 //  - ctx: a context for cancelling or timing out the request
+//  - opts: list options, such as WithAttributes, that customize the query
 //
 // It returns the following values:
 //  - groupsResponse: a GroupsResponse struct containing the details of the retrieved groups if the operation was successful
 //  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
 //  - err: an error value if there was an issue with the request or response
-func (c *Client) GroupList(ctx context.Context) (groupsResponse GroupsResponse, groupErrorResponse GroupErrorResponse, err error) {
+func (c *Client) GroupList(ctx context.Context, opts ...ListOption) (groupsResponse GroupsResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.GroupListPage(ctx, 0, 0, opts...)
+}
+
+// GroupListPage is a function that retrieves a single page of groups from the New Relic SCIM API.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - startIndex: the 1-based index of the first group to return, left out of the request if lower than 1
+//  - count: the maximum number of groups in the page, left out of the request if lower than 1
+//  - opts: list options, such as WithAttributes, that customize the query
+//
+// It returns the following values:
+//  - groupsResponse: a GroupsResponse struct containing the details of the retrieved groups if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) GroupListPage(ctx context.Context, startIndex int, count int, opts ...ListOption) (groupsResponse GroupsResponse, groupErrorResponse GroupErrorResponse, err error) {
 	// Construct the full URL for the request
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, groupPath)
+	fullUrl := c.resourceURL(groupPath)
 
 	// Create a new HTTP GET request
-	req, err := http.NewRequest("Get", fullUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
 		return groupsResponse, groupErrorResponse, err
 	}
 
+	// Add the paging parameters and the list options, such as the attributes to return, to the request URL
+	q := req.URL.Query()
+	if startIndex > 0 {
+		q.Add("startIndex", strconv.Itoa(startIndex))
+	}
+	if count > 0 {
+		q.Add("count", strconv.Itoa(count))
+	}
+	if err := applyListOptions(q, opts); err != nil {
+		return groupsResponse, groupErrorResponse, err
+	}
+	req.URL.RawQuery = q.Encode()
+
 	// Send the request and get the response
-	resp, err := c.doRequest(req)
+	raw, err := c.doRequestRaw(req)
 	if err != nil {
-		return groupsResponse, groupErrorResponse, err
+		return groupsResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
+	groupsResponse.Raw = raw
+	resp := raw.Body
 
 	// Unmarshal the response into a GroupsResponse struct
 	if err := json.Unmarshal(resp, &groupsResponse); err != nil {
 		return groupsResponse, groupErrorResponse, err
 	}
+	resourceTypes := make([]string, len(groupsResponse.Resources))
+	for i, group := range groupsResponse.Resources {
+		resourceTypes[i] = group.Meta.ResourceType
+	}
+	c.checkResourceTypes(req, ResourceTypeGroup, resourceTypes)
 
 	// If the response is an error, unmarshal it into a GroupErrorResponse struct
-	if groupsResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	if isErrorResponse(groupsResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupsResponse, groupErrorResponse, err
 		}
@@ -251,6 +387,55 @@ func (c *Client) GroupList(ctx context.Context) (groupsResponse GroupsResponse,
 	return groupsResponse, groupErrorResponse, nil
 }
 
+// GroupListAll is a function that retrieves every group from the New Relic SCIM API by requesting pages of
+// maxPageCount groups until TotalResults groups have been collected. The context is checked between page fetches.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the requests
+//  - opts: list options, such as WithAttributes, that customize the query of every page
+//
+// It returns the following values:
+//  - groups: a slice of GroupResource structs containing every group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with one of the requests or responses
+func (c *Client) GroupListAll(ctx context.Context, opts ...ListOption) (groups []GroupResource, groupErrorResponse GroupErrorResponse, err error) {
+	startIndex := 1
+	for {
+		if err := ctx.Err(); err != nil {
+			return groups, groupErrorResponse, err
+		}
+		groupsResponse, groupErrorResponse, err := c.GroupListPage(ctx, startIndex, maxPageCount, opts...)
+		if err != nil || groupErrorResponse.Status != "" {
+			return groups, groupErrorResponse, err
+		}
+		groups = append(groups, groupsResponse.Resources...)
+		if len(groupsResponse.Resources) == 0 || len(groups) >= groupsResponse.TotalResults {
+			return groups, groupErrorResponse, nil
+		}
+		startIndex += len(groupsResponse.Resources)
+	}
+}
+
+// CountGroups is a function that returns the number of groups in the New Relic SCIM API without fetching them. It
+// requests a single page of one group with only its id attribute and reads TotalResults.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - opts: list options that narrow the groups counted
+//
+// It returns the following values:
+//  - count: the number of matching groups if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) CountGroups(ctx context.Context, opts ...ListOption) (count int, groupErrorResponse GroupErrorResponse, err error) {
+	opts = append(opts[:len(opts):len(opts)], WithAttributes("id"))
+	groupsResponse, groupErrorResponse, err := c.GroupListPage(ctx, 1, 1, opts...)
+	if err != nil || groupErrorResponse.Status != "" {
+		return count, groupErrorResponse, err
+	}
+	return groupsResponse.TotalResults, groupErrorResponse, nil
+}
+
 // GetGroupByID fetches a group by its ID using the SCIM API.
 //
 // It takes the following arguments:
@@ -264,19 +449,21 @@ func (c *Client) GroupList(ctx context.Context) (groupsResponse GroupsResponse,
 func (c *Client) GetGroupByID(ctx context.Context, groupID string) (groupsResponse GroupsResponse, groupErrorResponse GroupErrorResponse, err error) {
 
 	// Construct the full URL for the request
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, groupPath, groupID)
+	fullUrl := c.resourceURL(groupPath, groupID)
 
 	// Create a new HTTP GET request
-	req, err := http.NewRequest("GET", fullUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
 		return groupsResponse, groupErrorResponse, err
 	}
 
 	// Send the request and get the response
-	resp, err := c.doRequest(req)
+	raw, err := c.doRequestRaw(req)
 	if err != nil {
-		return groupsResponse, groupErrorResponse, err
+		return groupsResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
+	groupsResponse.Raw = raw
+	resp := raw.Body
 
 	// Unmarshal the response into a GroupsResponse struct
 	if err := json.Unmarshal(resp, &groupsResponse); err != nil {
@@ -284,7 +471,7 @@ func (c *Client) GetGroupByID(ctx context.Context, groupID string) (groupsRespon
 	}
 
 	// If the response is an error, unmarshal it into a GroupErrorResponse struct
-	if groupsResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	if isErrorResponse(groupsResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupsResponse, groupErrorResponse, err
 		}
@@ -293,6 +480,49 @@ func (c *Client) GetGroupByID(ctx context.Context, groupID string) (groupsRespon
 	return groupsResponse, groupErrorResponse, nil
 }
 
+// GetGroupMembers is a function that retrieves the members of a group using the New Relic SCIM API.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - groupID: the ID of the group whose members are retrieved
+//
+// It returns the following values:
+//  - members: a slice of GroupMember structs, whose Value fields hold the member user IDs, if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) GetGroupMembers(ctx context.Context, groupID string) (members []GroupMember, groupErrorResponse GroupErrorResponse, err error) {
+	// Construct the full URL for the request
+	fullUrl := c.resourceURL(groupPath, groupID)
+
+	// Create a new HTTP GET request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
+	if err != nil {
+		return members, groupErrorResponse, err
+	}
+
+	// Send the request and get the response
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return members, GroupErrorResponse(errorResponseOf(err)), err
+	}
+
+	// Unmarshal the response into a GroupResource struct, which decodes the members into GroupMember structs
+	var group GroupResource
+	if err := json.Unmarshal(resp, &group); err != nil {
+		return members, groupErrorResponse, err
+	}
+
+	// If the response is an error, unmarshal it into a GroupErrorResponse struct
+	if isErrorResponse(group.Schemas) {
+		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
+			return members, groupErrorResponse, err
+		}
+		return members, groupErrorResponse, nil
+	}
+
+	return group.Members, groupErrorResponse, nil
+}
+
 // GetGroupByName is a function that retrieves a group by its name using the New Relic SCIM API.
 //
 // It takes the following arguments:
@@ -305,25 +535,27 @@ func (c *Client) GetGroupByID(ctx context.Context, groupID string) (groupsRespon
 //  - err: an error value if there was an issue with the request or response
 func (c *Client) GetGroupByName(ctx context.Context, groupName string) (groupsResponse GroupsResponse, groupErrorResponse GroupErrorResponse, err error) {
 	// Construct the full URL for the request
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, groupPath)
+	fullUrl := c.resourceURL(groupPath)
 
 	// Create a new HTTP GET request
-	req, err := http.NewRequest("GET", fullUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
 		return groupsResponse, groupErrorResponse, err
 	}
 
 	// Add the filter parameter to the request URL to filter the results by group name
 	q := req.URL.Query()
-	filter := fmt.Sprintf(`displayName eq "%s"`, groupName)
+	filter := FilterEq("displayName", groupName)
 	q.Add("filter", filter)
 	req.URL.RawQuery = q.Encode()
 
 	// Send the request and get the response
-	resp, err := c.doRequest(req)
+	raw, err := c.doRequestRaw(req)
 	if err != nil {
-		return groupsResponse, groupErrorResponse, err
+		return groupsResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
+	groupsResponse.Raw = raw
+	resp := raw.Body
 
 	// Unmarshal the response into a GroupsResponse struct
 	if err := json.Unmarshal(resp, &groupsResponse); err != nil {
@@ -331,7 +563,7 @@ func (c *Client) GetGroupByName(ctx context.Context, groupName string) (groupsRe
 	}
 
 	// If the response is an error, unmarshal it into a GroupErrorResponse struct
-	if groupsResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	if isErrorResponse(groupsResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupsResponse, groupErrorResponse, err
 		}
@@ -340,82 +572,330 @@ func (c *Client) GetGroupByName(ctx context.Context, groupName string) (groupsRe
 	return groupsResponse, groupErrorResponse, nil
 }
 
+// SearchGroups is a function that retrieves every group matching a SCIM filter, such as one built with
+// FilterStartsWith or FilterContains for prefix or partial name matches, following pagination until all matches have
+// been collected.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the requests
+//  - filter: the SCIM filter expression, such as FilterStartsWith("displayName", "team-")
+//  - opts: further list options, such as WithAttributes, that customize the query of every page
+//
+// It returns the following values:
+//  - groups: a slice of GroupResource structs containing every matching group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with one of the requests or responses
+func (c *Client) SearchGroups(ctx context.Context, filter string, opts ...ListOption) (groups []GroupResource, groupErrorResponse GroupErrorResponse, err error) {
+	opts = append(opts[:len(opts):len(opts)], WithFilter(filter))
+	return c.GroupListAll(ctx, opts...)
+}
+
+// FindGroupByName retrieves the single group whose display name is exactly groupName.
+//
+// Display names are not unique on New Relic, so the groups returned by GetGroupByName are matched against groupName
+// exactly. If no group matches, an error wrapping ErrNotFound is returned. If more than one group matches, an error
+// wrapping ErrMultipleMatches is returned.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - groupName: the name of the group to retrieve
+//
+// It returns the following values:
+//  - groupResponse: a GroupResponse struct containing the details of the matching group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response, or if not exactly one group matched
+func (c *Client) FindGroupByName(ctx context.Context, groupName string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	groupsResponse, groupErrorResponse, err := c.GetGroupByName(ctx, groupName)
+	if err != nil || groupErrorResponse.Status != "" {
+		return groupResponse, groupErrorResponse, err
+	}
+
+	var matches []GroupResource
+	for _, group := range groupsResponse.Resources {
+		if group.DisplayName == groupName {
+			matches = append(matches, group)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return groupResponse, groupErrorResponse, fmt.Errorf("group %q: %w", groupName, ErrNotFound)
+	case 1:
+		return matches[0].groupResponse(), groupErrorResponse, nil
+	default:
+		return groupResponse, groupErrorResponse, fmt.Errorf("group %q: %d groups found: %w", groupName, len(matches), ErrMultipleMatches)
+	}
+}
+
 // GroupMemberOps is a function that performs an operation on a group member in the New Relic SCIM API.
 //
 // It takes the following arguments:
 //  - ctx: a context for cancelling or timing out the request
 //  - groupID: the ID of the group to perform the operation on
 //  - userID: the ID of the user to perform the operation on
-//  - operation: the operation to perform on the group member (e.g. "add", "remove")
+//  - operation: the operation to perform on the group member, "add" or "remove", matched case-insensitively; any
+//    other operation, including "replace", fails with a *ValidationError reporting ErrInvalidMemberOperation
 //
 // It returns the following values:
 //  - groupResponse: a GroupResponse struct containing the details of the modified group if the operation was successful
 //  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
 //  - err: an error value if there was an issue with the request or response
 func (c *Client) GroupMemberOps(ctx context.Context, groupID string, userID string, operation string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	op, err := ParsePatchOpType(operation)
+	if err != nil || op == OpReplace {
+		var validationErr ValidationError
+		validationErr.add("op", fmt.Errorf("%w, got %q", ErrInvalidMemberOperation, operation))
+		return groupResponse, groupErrorResponse, validationErr.errOrNil()
+	}
+	return c.groupMembersOps(ctx, groupID, []string{userID}, op)
+}
+
+// groupMembersOps performs an operation on several group members, split into PATCH requests of at most
+// MaxMembersPerPatch users. The response of the last request, which reflects every change, is returned. If a request
+// fails after earlier ones were applied, the error is a *MemberBatchError listing the users that were changed.
+func (c *Client) groupMembersOps(ctx context.Context, groupID string, userIDs []string, operation PatchOpType) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	if len(userIDs) == 0 {
+		return groupResponse, groupErrorResponse, nil
+	}
+	size := c.MaxMembersPerPatch
+	if size <= 0 || len(userIDs) <= size {
+		return c.groupMembersPatch(ctx, groupID, userIDs, operation)
+	}
+	for start := 0; start < len(userIDs); start += size {
+		end := start + size
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		groupResponse, groupErrorResponse, err = c.groupMembersPatch(ctx, groupID, userIDs[start:end], operation)
+		if err != nil && start > 0 {
+			err = &MemberBatchError{Applied: userIDs[:start], Failed: userIDs[start:], Err: err}
+		}
+		if err != nil || groupErrorResponse.Status != "" {
+			return groupResponse, groupErrorResponse, err
+		}
+	}
+	return groupResponse, groupErrorResponse, nil
+}
+
+// groupMembersPatch performs an operation on several group members with a single PATCH request.
+//
+// Remove operations are sent as one operation per user with a value filtered path, such as
+// members[value eq "userID"], so that only the given members are removed from the group.
+func (c *Client) groupMembersPatch(ctx context.Context, groupID string, userIDs []string, operation PatchOpType) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	if operation == OpRemove {
+		ops := make([]PatchOperation, len(userIDs))
+		for i, userID := range userIDs {
+			ops[i] = RemoveOperation(fmt.Sprintf(`members[value eq %s]`, filterValue(userID)))
+		}
+		return c.patchGroup(ctx, groupID, ops)
+	}
+
+	return c.patchGroup(ctx, groupID, []PatchOperation{
+		AddOperation("members", memberValues(userIDs)),
+	})
+}
+
+// memberValues converts user IDs into the member values of a group patch operation.
+func memberValues(userIDs []string) []PatchValue {
+	values := make([]PatchValue, len(userIDs))
+	for i, userID := range userIDs {
+		values[i].Value = userID
+	}
+	return values
+}
+
+// ReplaceGroupMembers is a function that sets the members of a group to exactly the given users with a single PATCH
+// replace operation on the members path. Users not in the list are removed from the group.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - groupID: the ID of the group whose members are replaced
+//  - userIDs: the IDs of all users that should be members of the group
+//
+// It returns the following values:
+//  - groupResponse: a GroupResponse struct containing the details of the modified group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) ReplaceGroupMembers(ctx context.Context, groupID string, userIDs []string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.patchGroup(ctx, groupID, []PatchOperation{
+		ReplaceOperation("members", memberValues(userIDs)),
+	})
+}
+
+// SyncGroupMembersResult summarizes the changes made by SyncGroupMembers.
+//
+// It has the following fields:
+//  - Added: the IDs of the users that were added to the group
+//  - Removed: the IDs of the users that were removed from the group
+type SyncGroupMembersResult struct {
+	Added   []string
+	Removed []string
+}
+
+// SyncGroupMembers is a function that makes the members of a group match the desired users with the smallest set of
+// changes. It fetches the current members, then adds the missing users and removes the extra ones with one PATCH
+// request each, skipping a request when there is nothing to change. Members that are already correct are not touched.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the requests
+//  - groupID: the ID of the group to synchronize
+//  - desired: the IDs of all users that should be members of the group
+//
+// It returns the following values:
+//  - result: a SyncGroupMembersResult struct listing the users that were added and removed
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if an operation was not successful
+//  - err: an error value if there was an issue with one of the requests or responses
+func (c *Client) SyncGroupMembers(ctx context.Context, groupID string, desired []string) (result SyncGroupMembersResult, groupErrorResponse GroupErrorResponse, err error) {
+	members, groupErrorResponse, err := c.GetGroupMembers(ctx, groupID)
+	if err != nil || groupErrorResponse.Status != "" {
+		return result, groupErrorResponse, err
+	}
+
+	current := make(map[string]bool, len(members))
+	for _, member := range members {
+		current[member.Value] = true
+	}
+	wanted := make(map[string]bool, len(desired))
+	for _, userID := range desired {
+		if !wanted[userID] && !current[userID] {
+			result.Added = append(result.Added, userID)
+		}
+		wanted[userID] = true
+	}
+	for _, member := range members {
+		if !wanted[member.Value] {
+			result.Removed = append(result.Removed, member.Value)
+		}
+	}
+
+	if len(result.Added) > 0 {
+		_, groupErrorResponse, err = c.AddUsersToGroup(ctx, groupID, result.Added)
+		if err != nil || groupErrorResponse.Status != "" {
+			return result, groupErrorResponse, err
+		}
+	}
+	if len(result.Removed) > 0 {
+		_, groupErrorResponse, err = c.RemoveUsersFromGroup(ctx, groupID, result.Removed)
+		if err != nil || groupErrorResponse.Status != "" {
+			return result, groupErrorResponse, err
+		}
+	}
+
+	return result, groupErrorResponse, nil
+}
+
+// RenameGroup changes the display name of a group using a SCIM PATCH replace operation.
+//
+// Unlike UpdateGroup, which replaces the whole group resource, RenameGroup leaves the group members intact.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - groupID: the ID of the group to rename
+//  - newName: the new display name of the group
+//
+// It returns the following values:
+//  - groupResponse: a GroupResponse struct containing the details of the renamed group if the operation was successful
+//  - groupErrorResponse: a GroupErrorResponse struct containing details of the error if the operation was not successful
+//  - err: an error value if there was an issue with the request or response
+func (c *Client) RenameGroup(ctx context.Context, groupID string, newName string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.patchGroup(ctx, groupID, []PatchOperation{
+		ReplaceOperation("displayName", newName),
+	})
+}
+
+// patchGroup sends a SCIM PATCH request with the given operations to a group and decodes the response.
+func (c *Client) patchGroup(ctx context.Context, groupID string, ops []PatchOperation) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	fullUrl := c.resourceURL(groupPath, groupID)
+	patchRequest := PatchRequest{
+		Operations: ops,
+	}
+	patchRequest.fill_defaults()
+	if err := patchRequest.validate(); err != nil {
+		return groupResponse, groupErrorResponse, err
+	}
 
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, groupPath, groupID)
 	//Encode the data
-	updateGroup := UpdateGroup{
-		Operations: []struct {
-			Op    string "json:\"op\""
-			Path  string "json:\"path\""
-			Value []struct {
-				Value string "json:\"value\""
-			} "json:\"value\""
-		}{
-			{Op: operation, Path: "members", Value: []struct {
-				Value string "json:\"value\""
-			}{{Value: userID}}},
-		},
-	}
-	updateGroup.fill_defaults()
-
-	putBody, _ := json.Marshal(updateGroup)
-	requestBody := bytes.NewBuffer(putBody)
-
-	req, err := http.NewRequest("PATCH", fullUrl, requestBody)
+	patchBody, _ := json.Marshal(patchRequest)
+	requestBody := bytes.NewBuffer(patchBody)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, fullUrl, requestBody)
 	if err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
 
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return groupResponse, groupErrorResponse, err
+		return groupResponse, GroupErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &groupResponse); err != nil {
 		return groupResponse, groupErrorResponse, err
 	}
-	if groupResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	groupResponse.ETag = etag(header, groupResponse.Meta.Version)
+	if isErrorResponse(groupResponse.Schemas) {
 		if err := json.Unmarshal(resp, &groupErrorResponse); err != nil {
 			return groupResponse, groupErrorResponse, err
 		}
-
 	}
 
 	return groupResponse, groupErrorResponse, nil
 }
 
+// AddUserToGroup adds a single user to a group.
 func (c *Client) AddUserToGroup(ctx context.Context, groupID string, userID string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
-	return c.GroupMemberOps(ctx, groupID, userID, "Add")
+	return c.groupMembersOps(ctx, groupID, []string{userID}, OpAdd)
 }
 
+// RemoveUserFromGroup removes a single user from a group.
+func (c *Client) RemoveUserFromGroup(ctx context.Context, groupID string, userID string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.groupMembersOps(ctx, groupID, []string{userID}, OpRemove)
+}
+
+// RemoveUserToGroup removes a single user from a group.
+//
+// Deprecated: Use RemoveUserFromGroup instead.
 func (c *Client) RemoveUserToGroup(ctx context.Context, groupID string, userID string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
-	return c.GroupMemberOps(ctx, groupID, userID, "Remove")
+	return c.RemoveUserFromGroup(ctx, groupID, userID)
 }
 
-func (c *Client) DeleteGroup(ctx context.Context, groupID string) (err error) {
+// AddUsersToGroup adds several users to a group, with one PATCH request per MaxMembersPerPatch users. An empty list
+// sends no request and returns a zero GroupResponse without an error.
+func (c *Client) AddUsersToGroup(ctx context.Context, groupID string, userIDs []string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.groupMembersOps(ctx, groupID, userIDs, OpAdd)
+}
 
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, groupPath, groupID)
+// RemoveUsersFromGroup removes several users from a group, with one PATCH request per MaxMembersPerPatch users. An
+// empty list sends no request and returns a zero GroupResponse without an error.
+func (c *Client) RemoveUsersFromGroup(ctx context.Context, groupID string, userIDs []string) (groupResponse GroupResponse, groupErrorResponse GroupErrorResponse, err error) {
+	return c.groupMembersOps(ctx, groupID, userIDs, OpRemove)
+}
 
-	req, err := http.NewRequest("DELETE", fullUrl, nil)
-	if err != nil {
+// DeleteGroup deletes the group with the given ID. Responses without a body, such as 204 No Content, are accepted.
+func (c *Client) DeleteGroup(ctx context.Context, groupID string) (err error) {
+	return c.doDelete(ctx, c.resourceURL(groupPath, groupID))
+}
+
+// DeleteGroupIfExists deletes a group like DeleteGroup, but treats a group that is already gone as a successful no-op.
+func (c *Client) DeleteGroupIfExists(ctx context.Context, groupID string) (err error) {
+	if err := c.DeleteGroup(ctx, groupID); err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
+	return nil
+}
 
-	_, err = c.doRequest(req)
+// DeleteGroupByName looks up the group whose display name is exactly displayName with FindGroupByName and deletes it.
+//
+// Display names are not unique on New Relic, so nothing is deleted unless exactly one group matches.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the request
+//  - displayName: the display name of the group to delete
+//
+// It returns the following values:
+//  - err: an error value if there was an issue with the request or response, wrapping ErrNotFound if no group matched
+//    or ErrMultipleMatches if more than one group matched
+func (c *Client) DeleteGroupByName(ctx context.Context, displayName string) (err error) {
+	group, _, err := c.FindGroupByName(ctx, displayName)
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.DeleteGroup(ctx, group.ID)
 }
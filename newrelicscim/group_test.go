@@ -0,0 +1,172 @@
+package newrelicscim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroupMemberOpsLowercase(t *testing.T) {
+	var sent PatchRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent = PatchRequest{}
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"id":"group-1"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context, groupID string, userID string) (GroupResponse, GroupErrorResponse, error)
+		want PatchOpType
+	}{
+		{"AddUserToGroup", c.AddUserToGroup, "add"},
+		{"RemoveUserFromGroup", c.RemoveUserFromGroup, "remove"},
+	}
+	for _, tt := range tests {
+		if _, err := Result(tt.call(context.Background(), "group-1", "user-1")); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(sent.Operations) != 1 || sent.Operations[0].Op != tt.want {
+			t.Errorf("%s sent operations %+v, want a single %q operation", tt.name, sent.Operations, tt.want)
+		}
+	}
+}
+
+func TestGroupMemberOpsRejectsReplace(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	for _, operation := range []string{"replace", "Replace", "move"} {
+		_, err := Result(c.GroupMemberOps(context.Background(), "group-1", "user-1", operation))
+		var validationErr *ValidationError
+		if !errors.Is(err, ErrInvalidMemberOperation) || !errors.As(err, &validationErr) {
+			t.Errorf("GroupMemberOps(%q) error = %v, want a *ValidationError reporting ErrInvalidMemberOperation", operation, err)
+		}
+	}
+}
+
+func TestSearchGroupsPagesWithCallerFilter(t *testing.T) {
+	var filters []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters = append(filters, r.URL.Query().Get("filter"))
+		startIndex := r.URL.Query().Get("startIndex")
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],"totalResults":3,"itemsPerPage":1,` +
+			`"startIndex":` + startIndex + `,"Resources":[{"id":"group-` + startIndex + `","displayName":"team-` + startIndex + `"}]}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	groups, err := Result(c.SearchGroups(context.Background(), FilterStartsWith("displayName", "team-"), WithFilter(`externalId eq "x"`)))
+	if err != nil {
+		t.Fatalf("SearchGroups: %v", err)
+	}
+	if len(groups) != 3 || groups[2].ID != "group-3" {
+		t.Errorf("SearchGroups = %+v, want the three groups of three pages", groups)
+	}
+	want := `(externalId eq "x") and (displayName sw "team-")`
+	if len(filters) != 3 {
+		t.Fatalf("SearchGroups sent %d requests, want 3", len(filters))
+	}
+	for i, filter := range filters {
+		if filter != want {
+			t.Errorf("page %d: filter = %s, want %s", i+1, filter, want)
+		}
+	}
+}
+
+func TestGroupMembersEmptyList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	for _, userIDs := range [][]string{nil, {}} {
+		if _, err := Result(c.AddUsersToGroup(context.Background(), "group-1", userIDs)); err != nil {
+			t.Errorf("AddUsersToGroup(%#v): %v", userIDs, err)
+		}
+		if _, err := Result(c.RemoveUsersFromGroup(context.Background(), "group-1", userIDs)); err != nil {
+			t.Errorf("RemoveUsersFromGroup(%#v): %v", userIDs, err)
+		}
+	}
+}
+
+// memberPatchServer records the user IDs of every member PATCH request and fails the request numbered failAt, counting
+// from 1, with 400 Bad Request. A failAt of 0 fails none.
+func memberPatchServer(t *testing.T, failAt int) (*httptest.Server, *[][]string) {
+	var chunks [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Operations []struct {
+				Op    string       `json:"op"`
+				Value []PatchValue `json:"value"`
+			} `json:"Operations"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Operations) != 1 {
+			t.Errorf("request body: %+v, %v", body, err)
+		}
+		var ids []string
+		for _, value := range body.Operations[0].Value {
+			ids = append(ids, value.Value)
+		}
+		chunks = append(chunks, ids)
+		if len(chunks) == failAt {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","detail":"bad member"}`))
+			return
+		}
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:Group"],"id":"group-1"}`))
+	}))
+	return srv, &chunks
+}
+
+func TestAddUsersToGroupChunks(t *testing.T) {
+	userIDs := []string{"u1", "u2", "u3", "u4", "u5"}
+
+	srv, chunks := memberPatchServer(t, 0)
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL), WithMaxMembersPerPatch(2))
+	if _, err := Result(c.AddUsersToGroup(context.Background(), "group-1", userIDs)); err != nil {
+		t.Fatalf("AddUsersToGroup: %v", err)
+	}
+	if got, want := fmt.Sprint(*chunks), "[[u1 u2] [u3 u4] [u5]]"; got != want {
+		t.Errorf("PATCH requests carried %s, want %s", got, want)
+	}
+}
+
+func TestAddUsersToGroupPartialFailure(t *testing.T) {
+	userIDs := []string{"u1", "u2", "u3", "u4", "u5"}
+
+	srv, chunks := memberPatchServer(t, 2)
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL), WithMaxMembersPerPatch(2))
+	_, err := Result(c.AddUsersToGroup(context.Background(), "group-1", userIDs))
+
+	var batchErr *MemberBatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("AddUsersToGroup error = %v, want a *MemberBatchError", err)
+	}
+	if got := fmt.Sprint(batchErr.Applied, batchErr.Failed); got != "[u1 u2] [u3 u4 u5]" {
+		t.Errorf("Applied, Failed = %s, want [u1 u2] [u3 u4 u5]", got)
+	}
+	apiErr, ok := errors.Unwrap(err).(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Detail != "bad member" {
+		t.Errorf("errors.Unwrap = %#v, want the *APIError of the second chunk", errors.Unwrap(err))
+	}
+	if len(*chunks) != 2 {
+		t.Errorf("sent %d PATCH requests, want 2 with none after the failure", len(*chunks))
+	}
+}
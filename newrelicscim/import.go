@@ -0,0 +1,185 @@
+package newrelicscim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ImportResult is the outcome of creating one user with ImportUsers.
+//
+// It has the following fields:
+//  - UserName: the userName of the user, to report which row of the import it belongs to
+//  - ID: the ID of the created user, empty if the user was not created
+//  - Err: the reason the user was not created, such as a *ValidationError or an *APIError wrapping ErrConflict
+type ImportResult struct {
+	UserName string
+	ID       string
+	Err      error
+}
+
+// ImportUsers creates many users at once, such as the rows of a spreadsheet, and reports the outcome of every user
+// rather than stopping at the first failure.
+//
+// Users that fail client-side validation are reported without being sent. If the service provider configuration
+// advertises bulk support, the other users are created with Bulk requests that stay within the advertised maximum
+// number of operations and payload size; a single user whose operation alone exceeds the payload size is still sent on
+// its own. Otherwise they are created with CreateUser, with up to ImportConcurrency requests in flight. The limit set
+// with WithRateLimit and the retries of rate limited responses apply to every request either way.
+//
+// In dry run mode the users are always passed to CreateUser, which logs them instead of sending them, so every valid
+// user is reported without an error and without an ID.
+//
+// It takes the following arguments:
+//  - ctx: a context for cancelling or timing out the import
+//  - users: the users to create
+//
+// It returns the following values:
+//  - results: one ImportResult for every user, in the same order as users
+//  - err: the context error if the import was cancelled before every user was sent, nil otherwise
+func (c *Client) ImportUsers(ctx context.Context, users []User) (results []ImportResult, err error) {
+	results = make([]ImportResult, len(users))
+	prepared := make([]User, len(users))
+	var pending []int
+	for i, user := range users {
+		results[i].UserName = user.UserName
+		user.fill_defaults(c.DefaultTimezone, Bool(c.DefaultActive))
+		if err := user.validate(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		prepared[i] = user
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+	if c.DryRun {
+		c.importUsersConcurrently(ctx, prepared, pending, results)
+		return results, ctx.Err()
+	}
+
+	config, _, err := c.GetServiceProviderConfig(ctx)
+	if err == nil && config.Bulk.Supported {
+		c.importUsersBulk(ctx, prepared, pending, config.Bulk.MaxOperations, config.Bulk.MaxPayloadSize, results)
+	} else {
+		if err != nil {
+			c.debugf("scim service provider configuration unavailable, importing users one by one: %v", err)
+		}
+		c.importUsersConcurrently(ctx, prepared, pending, results)
+	}
+	return results, ctx.Err()
+}
+
+// importUsersBulk creates the users at the pending indexes with Bulk requests of at most maxOperations operations and
+// maxPayloadSize bytes, recording the outcome of every user in results. A limit of 0 or less is no limit. The index of
+// a user is its bulkId, so that the results can be matched to the users. A failed request fails every user it carried,
+// while later requests are still sent.
+func (c *Client) importUsersBulk(ctx context.Context, users []User, pending []int, maxOperations int, maxPayloadSize int, results []ImportResult) {
+	ops := make([]BulkOperation, 0, len(pending))
+	for _, i := range pending {
+		ops = append(ops, BulkOperation{
+			Method: http.MethodPost,
+			BulkID: strconv.Itoa(i),
+			Path:   "/" + userPath,
+			Data:   users[i],
+		})
+	}
+
+	for _, batch := range bulkBatches(ops, maxOperations, maxPayloadSize) {
+		bulkResponse, err := Result(c.sendBulk(ctx, batch))
+		if err != nil {
+			for _, op := range batch {
+				i, _ := strconv.Atoi(op.BulkID)
+				results[i].Err = err
+			}
+			continue
+		}
+
+		done := make(map[int]bool, len(batch))
+		for _, op := range bulkResponse.Operations {
+			i, err := strconv.Atoi(op.BulkID)
+			if err != nil || i < 0 || i >= len(results) {
+				continue
+			}
+			done[i] = true
+			status, _ := strconv.Atoi(op.Status)
+			if status < 200 || status > 299 {
+				results[i].Err = newAPIError(status, nil, op.Response)
+				continue
+			}
+			results[i].ID = op.Location[strings.LastIndex(op.Location, "/")+1:]
+		}
+		for _, op := range batch {
+			i, _ := strconv.Atoi(op.BulkID)
+			if !done[i] {
+				results[i].Err = fmt.Errorf("bulk response has no result for user %q: %w", users[i].UserName, ErrUnexpectedResponse)
+			}
+		}
+	}
+}
+
+// bulkBatches splits ops into batches of at most maxOperations operations whose encoded bulk request is at most
+// maxPayloadSize bytes. A limit of 0 or less is no limit. An operation too large for any batch gets a batch of its own.
+func bulkBatches(ops []BulkOperation, maxOperations int, maxPayloadSize int) [][]BulkOperation {
+	envelope := BulkRequest{Operations: []BulkOperation{}}
+	envelope.fill_defaults()
+	encoded, _ := json.Marshal(envelope)
+	baseSize := len(encoded)
+
+	var batches [][]BulkOperation
+	var batch []BulkOperation
+	size := baseSize
+	for _, op := range ops {
+		encoded, _ := json.Marshal(op)
+		// Every operation after the first is preceded by a comma.
+		opSize := len(encoded) + 1
+		full := maxOperations > 0 && len(batch) >= maxOperations
+		tooLarge := maxPayloadSize > 0 && size+opSize > maxPayloadSize
+		if len(batch) > 0 && (full || tooLarge) {
+			batches = append(batches, batch)
+			batch, size = nil, baseSize
+		}
+		batch = append(batch, op)
+		size += opSize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// importUsersConcurrently creates the users at the pending indexes with CreateUser, sending up to ImportConcurrency
+// requests at the same time, and records the outcome of every user in results.
+func (c *Client) importUsersConcurrently(ctx context.Context, users []User, pending []int, results []ImportResult) {
+	workers := c.ImportConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				userResponse, err := Result(c.CreateUser(ctx, users[i]))
+				results[i].ID = userResponse.ID
+				results[i].Err = err
+			}
+		}()
+	}
+	for _, i := range pending {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,198 @@
+package newrelicscim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func importUser(userName string) User {
+	return User{UserName: userName, Emails: []Email{{Value: userName, Primary: true}}}
+}
+
+const bulkConfig = `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"],"bulk":{"supported":true,"maxOperations":2}}`
+
+func TestImportUsersBulk(t *testing.T) {
+	var batches []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/ServiceProviderConfig") {
+			w.Write([]byte(bulkConfig))
+			return
+		}
+		var bulkRequest BulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&bulkRequest); err != nil {
+			t.Errorf("bulk request: %v", err)
+		}
+		bulkResponse := BulkResponse{Schemas: []string{"urn:ietf:params:scim:api:messages:2.0:BulkResponse"}}
+		var ids []string
+		for _, op := range bulkRequest.Operations {
+			ids = append(ids, op.BulkID)
+			result := BulkOperationResponse{Method: op.Method, BulkID: op.BulkID, Status: "201", Location: "https://example.com/scim/v2/Users/id-" + op.BulkID}
+			if op.BulkID == "2" {
+				result = BulkOperationResponse{Method: op.Method, BulkID: op.BulkID, Status: "409",
+					Response: json.RawMessage(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"409","detail":"exists"}`)}
+			}
+			bulkResponse.Operations = append(bulkResponse.Operations, result)
+		}
+		batches = append(batches, strings.Join(ids, ","))
+		json.NewEncoder(w).Encode(bulkResponse)
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+
+	users := []User{importUser("a@example.com"), {UserName: "b@example.com"}, importUser("c@example.com"), importUser("d@example.com"), importUser("e@example.com")}
+	results, err := c.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if got := strings.Join(batches, " "); got != "0,2 3,4" {
+		t.Errorf("bulk requests carried bulkIds %s, want 0,2 3,4", got)
+	}
+	for i, want := range []string{"id-0", "", "", "id-3", "id-4"} {
+		if results[i].ID != want || results[i].UserName != users[i].UserName {
+			t.Errorf("results[%d] = %+v, want ID %q for %s", i, results[i], want, users[i].UserName)
+		}
+	}
+	if !errors.Is(results[1].Err, ErrMissingPrimaryEmail) {
+		t.Errorf("results[1].Err = %v, want ErrMissingPrimaryEmail", results[1].Err)
+	}
+	var apiErr *APIError
+	if !errors.As(results[2].Err, &apiErr) || !errors.Is(results[2].Err, ErrConflict) || apiErr.Detail != "exists" {
+		t.Errorf("results[2].Err = %v, want the 409 *APIError of the operation", results[2].Err)
+	}
+	for _, i := range []int{0, 3, 4} {
+		if results[i].Err != nil {
+			t.Errorf("results[%d].Err = %v, want nil", i, results[i].Err)
+		}
+	}
+}
+
+func TestBulkBatchesPayloadSize(t *testing.T) {
+	var ops []BulkOperation
+	for i := 0; i < 5; i++ {
+		ops = append(ops, BulkOperation{Method: http.MethodPost, BulkID: fmt.Sprint(i), Path: "/Users", Data: importUser(fmt.Sprintf("user%d@example.com", i))})
+	}
+	encoded, _ := json.Marshal(ops[0])
+	envelope := BulkRequest{Operations: []BulkOperation{}}
+	envelope.fill_defaults()
+	empty, _ := json.Marshal(envelope)
+	// Room for two operations, but not three.
+	maxPayloadSize := len(empty) + 2*(len(encoded)+1) + 1
+
+	batches := bulkBatches(ops, 0, maxPayloadSize)
+	var sizes []int
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+		body := BulkRequest{Operations: batch}
+		body.fill_defaults()
+		if encoded, _ := json.Marshal(body); len(encoded) > maxPayloadSize {
+			t.Errorf("batch of %d operations is %d bytes, want at most %d", len(batch), len(encoded), maxPayloadSize)
+		}
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+
+	if got := bulkBatches(ops, 0, 10); len(got) != 5 {
+		t.Errorf("bulkBatches with a tiny payload size made %d batches, want one per operation", len(got))
+	}
+	if got := bulkBatches(ops, 0, 0); len(got) != 1 {
+		t.Errorf("bulkBatches without limits made %d batches, want 1", len(got))
+	}
+}
+
+func TestImportUsersConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	created := map[string]bool{}
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		var user User
+		json.NewDecoder(r.Body).Decode(&user)
+		mu.Lock()
+		exists := created[user.UserName]
+		created[user.UserName] = true
+		mu.Unlock()
+		if exists {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"409","detail":"exists"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"id-` + user.UserName + `"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL), WithImportConcurrency(2))
+
+	var users []User
+	for i := 0; i < 6; i++ {
+		users = append(users, importUser(fmt.Sprintf("user%d@example.com", i)))
+	}
+	users = append(users, importUser("user0@example.com"))
+	results, err := c.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+
+	conflicts := 0
+	for i, result := range results {
+		switch {
+		case errors.Is(result.Err, ErrConflict):
+			conflicts++
+		case result.Err != nil || result.ID != "id-"+users[i].UserName:
+			t.Errorf("results[%d] = %+v, want the ID of %s", i, result, users[i].UserName)
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("%d users conflicted, want 1 for the duplicate", conflicts)
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got != 2 {
+		t.Errorf("at most %d requests were in flight, want 2", got)
+	}
+}
+
+func TestImportUsersDryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("dry run sent %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(bulkConfig))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL), WithDryRun())
+
+	users := []User{importUser("a@example.com"), {UserName: "b@example.com"}, importUser("c@example.com")}
+	results, err := c.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil || results[i].ID != "" {
+			t.Errorf("results[%d] = %+v, want no error and no ID", i, results[i])
+		}
+	}
+	if !errors.Is(results[1].Err, ErrMissingPrimaryEmail) {
+		t.Errorf("results[1].Err = %v, want ErrMissingPrimaryEmail", results[1].Err)
+	}
+}
@@ -0,0 +1,110 @@
+package newrelicscim
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Sort orders accepted by WithSortOrder.
+const (
+	SortAscending  = "ascending"
+	SortDescending = "descending"
+)
+
+// ListOption customizes the query of a list request, such as UserList or GroupList.
+type ListOption func(q url.Values) error
+
+// WithAttributes limits the attributes returned for each resource to the given attribute names, such as "id" and
+// "userName". Attributes that are left out keep their zero value in the decoded response.
+func WithAttributes(attributes ...string) ListOption {
+	return func(q url.Values) error {
+		q.Set("attributes", strings.Join(attributes, ","))
+		return nil
+	}
+}
+
+// WithExcludedAttributes removes the given attribute names, such as "groups", from each returned resource.
+// Attributes that are left out keep their zero value in the decoded response.
+func WithExcludedAttributes(attributes ...string) ListOption {
+	return func(q url.Values) error {
+		q.Set("excludedAttributes", strings.Join(attributes, ","))
+		return nil
+	}
+}
+
+// WithSortBy sorts the returned resources by the given attribute path, such as "userName" or "meta.created".
+func WithSortBy(attribute string) ListOption {
+	return func(q url.Values) error {
+		q.Set("sortBy", attribute)
+		return nil
+	}
+}
+
+// WithSortOrder sets the order used with WithSortBy. The order must be SortAscending or SortDescending; any other value
+// makes the list request fail before it is sent.
+func WithSortOrder(order string) ListOption {
+	return func(q url.Values) error {
+		if order != SortAscending && order != SortDescending {
+			return fmt.Errorf("invalid sort order %q, must be %q or %q", order, SortAscending, SortDescending)
+		}
+		q.Set("sortOrder", order)
+		return nil
+	}
+}
+
+// WithFilter limits the returned resources to those matching the given SCIM filter expression, such as
+// `userName eq "john"` as built by FilterEq, with string values quoted and their quotes escaped. Combined with another filter option,
+// both filters must match.
+func WithFilter(filter string) ListOption {
+	return func(q url.Values) error {
+		combined := filter
+		if existing := q.Get("filter"); existing != "" {
+			combined = fmt.Sprintf("(%s) and (%s)", existing, filter)
+		}
+		q.Set("filter", combined)
+		return nil
+	}
+}
+
+// FilterEq returns a SCIM filter matching resources whose attribute equals value, such as displayName eq "team-a".
+func FilterEq(attribute, value string) string {
+	return attribute + " eq " + filterValue(value)
+}
+
+// FilterStartsWith returns a SCIM filter matching resources whose attribute starts with value, such as
+// displayName sw "team-".
+func FilterStartsWith(attribute, value string) string {
+	return attribute + " sw " + filterValue(value)
+}
+
+// FilterContains returns a SCIM filter matching resources whose attribute contains value, such as
+// displayName co "ops".
+func FilterContains(attribute, value string) string {
+	return attribute + " co " + filterValue(value)
+}
+
+// WithActive limits the returned users to active or to inactive users, filtering on the server with active eq true or
+// active eq false.
+func WithActive(active bool) ListOption {
+	return WithFilter(fmt.Sprintf("active eq %t", active))
+}
+
+// filterValueReplacer escapes the characters that would end or alter a quoted string in a SCIM filter.
+var filterValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// filterValue quotes a value for use in a SCIM filter expression, such as userName eq "john", escaping embedded
+// backslashes and double quotes so the value cannot change the meaning of the filter.
+func filterValue(value string) string {
+	return `"` + filterValueReplacer.Replace(value) + `"`
+}
+
+// applyListOptions applies the given list options to the query values, stopping at the first option that fails.
+func applyListOptions(q url.Values, opts []ListOption) error {
+	for _, opt := range opts {
+		if err := opt(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package newrelicscim
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestFilterEqEscapesValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`john.doe@example.com`, `userName eq "john.doe@example.com"`},
+		{`o"brien`, `userName eq "o\"brien"`},
+		{`back\slash`, `userName eq "back\\slash"`},
+		{`we"ird\name`, `userName eq "we\"ird\\name"`},
+		{`\"`, `userName eq "\\\""`},
+	}
+	for _, tt := range tests {
+		if got := FilterEq("userName", tt.value); got != tt.want {
+			t.Errorf("FilterEq(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFilterValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{``, `""`},
+		{`plain`, `"plain"`},
+		{`"`, `"\""`},
+		{`\`, `"\\"`},
+		{`a"b\c`, `"a\"b\\c"`},
+	}
+	for _, tt := range tests {
+		if got := filterValue(tt.value); got != tt.want {
+			t.Errorf("filterValue(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWithFilterReusable(t *testing.T) {
+	active := WithActive(true)
+	userName := WithFilter(FilterStartsWith("userName", "a"))
+	want := `(active eq true) and (userName sw "a")`
+
+	// Each page applies the same options to fresh query values, and a second call reuses them again.
+	for page := 1; page <= 3; page++ {
+		q := url.Values{}
+		if err := applyListOptions(q, []ListOption{active, userName}); err != nil {
+			t.Fatalf("applyListOptions: %v", err)
+		}
+		if got := q.Get("filter"); got != want {
+			t.Errorf("page %d: filter = %s, want %s", page, got, want)
+		}
+	}
+
+	q := url.Values{}
+	if err := applyListOptions(q, []ListOption{WithActive(false), userName}); err != nil {
+		t.Fatalf("applyListOptions: %v", err)
+	}
+	if got, want := q.Get("filter"), `(active eq false) and (userName sw "a")`; got != want {
+		t.Errorf("reused option: filter = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package newrelicscim
+
+import "net/http"
+
+// Logger receives diagnostic messages from a Client. Messages use fmt.Printf style formatting.
+//
+// It has the following methods:
+//   - Debugf: called for every outgoing request and every response status
+//   - Errorf: called when a request fails before a response is received
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// WithLogger sets the Logger used to report requests and responses. Without it the client does not log.
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) {
+		c.Logger = logger
+	}
+}
+
+// debugf forwards a debug message to the client's Logger, if one is configured.
+func (c *Client) debugf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Debugf(format, args...)
+	}
+}
+
+// errorf forwards an error message to the client's Logger, if one is configured.
+func (c *Client) errorf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Errorf(format, args...)
+	}
+}
+
+// redactHeader returns a copy of the given headers with the Authorization value replaced, so the API token never
+// reaches a log.
+func redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	if redacted.Get("Authorization") != "" {
+		redacted.Set("Authorization", "REDACTED")
+	}
+	return redacted
+}
@@ -0,0 +1,110 @@
+package newrelicscim
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Meta represents the metadata the New Relic SCIM API returns for a user or group resource.
+//
+// It has the following fields:
+//  - ResourceType: the type of the resource, such as "User" or "Group"
+//  - Created: the time the resource was created
+//  - LastModified: the time the resource was last modified
+//  - Location: the URI of the resource
+//  - Version: the version of the resource, usable as an ETag for conditional requests
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+	Version      string    `json:"version"`
+}
+
+// Resource types reported in Meta.ResourceType.
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+// checkResourceTypes reports through the Logger, as an error, when resources returned by a list request are not of
+// the expected type, which points to a request routed to the wrong endpoint. Empty resource types, as returned when
+// meta is not among the requested attributes, are ignored. The response is still returned to the caller unchanged.
+func (c *Client) checkResourceTypes(req *http.Request, expected string, resourceTypes []string) {
+	unexpected := 0
+	for _, resourceType := range resourceTypes {
+		if resourceType != "" && resourceType != expected {
+			unexpected++
+		}
+	}
+	if unexpected > 0 {
+		c.errorf("scim response: %s %s returned %d of %d resources with an unexpected resourceType, want %q", req.Method, req.URL, unexpected, len(resourceTypes), expected)
+	}
+}
+
+// metaTimeLayouts are the timestamp formats accepted for Created and LastModified, tried in order. Besides RFC 3339,
+// they cover timestamps without a time zone, which are taken as UTC, and with a space instead of the T separator.
+var metaTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// UnmarshalJSON decodes the metadata, parsing Created and LastModified as a string in any of the metaTimeLayouts or as
+// a number of seconds or milliseconds since the Unix epoch. A timestamp in none of these forms leaves its field zero
+// instead of failing to decode the whole resource or list.
+func (m *Meta) UnmarshalJSON(data []byte) error {
+	type meta Meta
+	var raw struct {
+		meta
+		Created      json.RawMessage `json:"created"`
+		LastModified json.RawMessage `json:"lastModified"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Meta(raw.meta)
+	m.Created = parseMetaTime(raw.Created)
+	m.LastModified = parseMetaTime(raw.LastModified)
+	return nil
+}
+
+// epochMillisThreshold is the smallest numeric timestamp taken as milliseconds rather than seconds since the Unix
+// epoch. As seconds it would lie more than a thousand years ahead.
+const epochMillisThreshold = 1e11
+
+// parseMetaTime parses a JSON timestamp, either a string with the first matching layout of metaTimeLayouts or a number
+// of seconds or milliseconds since the Unix epoch. Anything else, including null, returns the zero time.
+func parseMetaTime(value json.RawMessage) time.Time {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		for _, layout := range metaTimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+		return time.Time{}
+	}
+	var epoch float64
+	if err := json.Unmarshal(value, &epoch); err == nil {
+		if math.Abs(epoch) >= epochMillisThreshold {
+			epoch /= 1000
+		}
+		seconds, fraction := math.Modf(epoch)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC()
+	}
+	return time.Time{}
+}
+
+// etag returns the ETag response header, falling back to the resource version from the response body when the header
+// is missing.
+func etag(header http.Header, version string) string {
+	if value := header.Get("ETag"); value != "" {
+		return value
+	}
+	return version
+}
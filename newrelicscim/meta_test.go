@@ -0,0 +1,41 @@
+package newrelicscim
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMetaUnmarshalTimestamps(t *testing.T) {
+	want := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	tests := []struct {
+		name    string
+		created string
+		want    time.Time
+	}{
+		{"RFC 3339", `"2023-11-14T22:13:20Z"`, want},
+		{"without time zone", `"2023-11-14T22:13:20"`, want},
+		{"space separator", `"2023-11-14 22:13:20"`, want},
+		{"epoch seconds", `1700000000`, want},
+		{"epoch milliseconds", `1700000000000`, want},
+		{"fractional epoch seconds", `1700000000.5`, want.Add(500 * time.Millisecond)},
+		{"unknown layout", `"14/11/2023"`, time.Time{}},
+		{"null", `null`, time.Time{}},
+		{"boolean", `true`, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var meta Meta
+			data := `{"resourceType":"User","created":` + tt.created + `,"lastModified":` + tt.created + `,"version":"W/\"1\""}`
+			if err := json.Unmarshal([]byte(data), &meta); err != nil {
+				t.Fatalf("json.Unmarshal: %v", err)
+			}
+			if !meta.Created.Equal(tt.want) || !meta.LastModified.Equal(tt.want) {
+				t.Errorf("created %v, lastModified %v, want %v", meta.Created, meta.LastModified, tt.want)
+			}
+			if meta.ResourceType != "User" || meta.Version != `W/"1"` {
+				t.Errorf("meta = %+v, want the other fields decoded", meta)
+			}
+		})
+	}
+}
@@ -0,0 +1,481 @@
+// Package newrelicscimtest provides an in-memory fake of the New Relic SCIM API for tests of code that uses the
+// newrelicscim client.
+//
+// The fake implements the Users and Groups endpoints, including eq, sw and co filters on userName, displayName and
+// active, paging with startIndex and count, PATCH operations and ETags, which is enough to exercise provisioning code
+// without calling the real API:
+//
+//	server := newrelicscimtest.NewServer()
+//	defer server.Close()
+//
+//	client := server.NewClient()
+//	user, _, err := client.CreateUser(ctx, newrelicscim.User{
+//		UserName: "john.doe@example.com",
+//		Emails:   []newrelicscim.Email{{Value: "john.doe@example.com", Primary: true}},
+//	})
+package newrelicscimtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/atilsensalduz/new-relic-scim-go-client/newrelicscim/newrelicscim"
+)
+
+// BasePath is the path under which the fake serves the SCIM API, mirroring the path of the real API.
+const BasePath = "/scim/v2/"
+
+const (
+	userPath  = "Users"
+	groupPath = "Groups"
+
+	listSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	errorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// filterPattern matches the simple "attribute op value" filters the client sends, with the eq, sw or co operator and a
+// quoted string or a boolean value.
+var filterPattern = regexp.MustCompile(`^\s*([A-Za-z.]+)\s+(eq|sw|co)\s+(?:"((?:[^"\\]|\\.)*)"|(true|false))\s*$`)
+
+// memberFilterPattern matches the value filtered member paths the client sends, such as members[value eq "id"].
+var memberFilterPattern = regexp.MustCompile(`^members\[value eq "((?:[^"\\]|\\.)*)"\]$`)
+
+// resource is a stored user or group, kept as decoded JSON so that any attribute sent by a client round-trips.
+type resource map[string]interface{}
+
+// Server is a fake New Relic SCIM API backed by in-memory state. Create one with NewServer and close it with Close.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	nextID int
+	users  map[string]resource
+	groups map[string]resource
+}
+
+// NewServer starts a fake New Relic SCIM API with no users or groups.
+func NewServer() *Server {
+	s := &Server{
+		users:  map[string]resource{},
+		groups: map[string]resource{},
+	}
+	s.Server = httptest.NewServer(http.StripPrefix(strings.TrimSuffix(BasePath, "/"), http.HandlerFunc(s.serveHTTP)))
+	return s
+}
+
+// BaseURL returns the SCIM base URL of the fake, to be used with newrelicscim.WithBaseURL.
+func (s *Server) BaseURL() string {
+	return s.URL + BasePath
+}
+
+// NewClient returns a client configured to talk to the fake. Retries are disabled so that failures surface
+// immediately; the given options are applied afterwards and can override that.
+func (s *Server) NewClient(opts ...newrelicscim.ClientOption) *newrelicscim.Client {
+	opts = append([]newrelicscim.ClientOption{
+		newrelicscim.WithBaseURL(s.BaseURL()),
+		newrelicscim.WithMaxRetries(0),
+	}, opts...)
+	return newrelicscim.NewClient("newrelicscimtest", opts...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "", "missing bearer token")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var store map[string]resource
+	switch parts[0] {
+	case userPath:
+		store = s.users
+	case groupPath:
+		store = s.groups
+	default:
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("unknown endpoint %q", r.URL.Path))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.list(w, r, parts[0], store)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.create(w, r, parts[0], store)
+	case len(parts) == 2:
+		res, ok := store[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "", fmt.Sprintf("resource %s not found", parts[1]))
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != res.meta()["version"] {
+			writeError(w, http.StatusPreconditionFailed, "", "resource version does not match")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.write(w, http.StatusOK, res)
+		case http.MethodPut:
+			s.replace(w, r, parts[0], store, res)
+		case http.MethodPatch:
+			s.patch(w, r, res)
+		case http.MethodDelete:
+			delete(store, parts[1])
+			if parts[0] == userPath {
+				s.removeMemberEverywhere(parts[1])
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// matchFilter reports whether an attribute value matches a filter value under a filter operator, ignoring case.
+func matchFilter(operator, attributeValue, value string) bool {
+	attributeValue, value = strings.ToLower(attributeValue), strings.ToLower(value)
+	switch operator {
+	case "sw":
+		return strings.HasPrefix(attributeValue, value)
+	case "co":
+		return strings.Contains(attributeValue, value)
+	}
+	return attributeValue == value
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, resourceType string, store map[string]resource) {
+	var matches []resource
+	filter := r.URL.Query().Get("filter")
+	var attribute, operator, value string
+	if filter != "" {
+		m := filterPattern.FindStringSubmatch(filter)
+		if m == nil {
+			writeError(w, http.StatusBadRequest, "invalidFilter", fmt.Sprintf("unsupported filter %q", filter))
+			return
+		}
+		attribute, operator, value = m[1], m[2], unescape(m[3])
+		if m[4] != "" {
+			value = m[4]
+		}
+	}
+	for _, res := range store {
+		if filter == "" || matchFilter(operator, fmt.Sprint(res.lookup(attribute)), value) {
+			matches = append(matches, res)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i]["id"].(string) < matches[j]["id"].(string)
+	})
+
+	startIndex, count := 1, len(matches)
+	if v, err := strconv.Atoi(r.URL.Query().Get("startIndex")); err == nil && v > 1 {
+		startIndex = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil && v >= 0 {
+		count = v
+	}
+	page := []interface{}{}
+	for i := startIndex - 1; i < len(matches) && len(page) < count; i++ {
+		page = append(page, s.render(matches[i]))
+	}
+
+	writeJSON(w, http.StatusOK, nil, map[string]interface{}{
+		"schemas":      []string{listSchema},
+		"totalResults": len(matches),
+		"itemsPerPage": len(page),
+		"startIndex":   startIndex,
+		"Resources":    page,
+	})
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, resourceType string, store map[string]resource) {
+	var res resource
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	if resourceType == userPath {
+		userName, _ := res["userName"].(string)
+		if userName == "" {
+			writeError(w, http.StatusBadRequest, "invalidValue", "userName is required")
+			return
+		}
+		for _, existing := range store {
+			existingName, _ := existing["userName"].(string)
+			if strings.EqualFold(existingName, userName) {
+				writeError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("user %q already exists", userName))
+				return
+			}
+		}
+	}
+
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	now := time.Now().UTC()
+	res["id"] = id
+	res["meta"] = map[string]interface{}{
+		"resourceType": strings.TrimSuffix(resourceType, "s"),
+		"created":      now,
+		"lastModified": now,
+		"location":     s.BaseURL() + resourceType + "/" + id,
+		"version":      `W/"1"`,
+	}
+	if resourceType == groupPath && res["members"] == nil {
+		res["members"] = []interface{}{}
+	}
+	store[id] = res
+	s.write(w, http.StatusCreated, res)
+}
+
+func (s *Server) replace(w http.ResponseWriter, r *http.Request, resourceType string, store map[string]resource, res resource) {
+	var body resource
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+	body["id"] = res["id"]
+	body["meta"] = res["meta"]
+	if resourceType == groupPath && body["members"] == nil {
+		body["members"] = []interface{}{}
+	}
+	store[res["id"].(string)] = body
+	body.touch()
+	s.write(w, http.StatusOK, body)
+}
+
+func (s *Server) patch(w http.ResponseWriter, r *http.Request, res resource) {
+	var body struct {
+		Operations []struct {
+			Op    string          `json:"op"`
+			Path  string          `json:"path"`
+			Value json.RawMessage `json:"value"`
+		} `json:"Operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	for _, op := range body.Operations {
+		var value interface{}
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				writeError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+				return
+			}
+		}
+		if err := res.apply(strings.ToLower(op.Op), op.Path, value); err != nil {
+			writeError(w, http.StatusBadRequest, "invalidPath", err.Error())
+			return
+		}
+	}
+	res.touch()
+	s.write(w, http.StatusOK, res)
+}
+
+// apply performs a single patch operation on the resource.
+func (res resource) apply(op string, path string, value interface{}) error {
+	if m := memberFilterPattern.FindStringSubmatch(path); m != nil {
+		if op != "remove" {
+			return fmt.Errorf("unsupported operation %q on %q", op, path)
+		}
+		res.removeMembers(map[string]bool{unescape(m[1]): true})
+		return nil
+	}
+
+	switch {
+	case path == "" && op != "remove":
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("operation %q without path needs an object value", op)
+		}
+		for k, v := range values {
+			res[k] = v
+		}
+	case path == "members":
+		ids := memberIDs(value)
+		switch op {
+		case "add":
+			res.addMembers(ids)
+		case "remove":
+			if value == nil {
+				res["members"] = []interface{}{}
+			} else {
+				remove := map[string]bool{}
+				for _, id := range ids {
+					remove[id] = true
+				}
+				res.removeMembers(remove)
+			}
+		case "replace":
+			res["members"] = []interface{}{}
+			res.addMembers(ids)
+		default:
+			return fmt.Errorf("unsupported operation %q", op)
+		}
+	case strings.ContainsAny(path, "[]"):
+		return fmt.Errorf("unsupported path %q", path)
+	default:
+		switch op {
+		case "add", "replace":
+			res[path] = value
+		case "remove":
+			delete(res, path)
+		default:
+			return fmt.Errorf("unsupported operation %q", op)
+		}
+	}
+	return nil
+}
+
+func (res resource) addMembers(ids []string) {
+	members, _ := res["members"].([]interface{})
+	existing := map[string]bool{}
+	for _, member := range members {
+		existing[memberID(member)] = true
+	}
+	for _, id := range ids {
+		if !existing[id] {
+			members = append(members, map[string]interface{}{"type": "User", "value": id})
+			existing[id] = true
+		}
+	}
+	res["members"] = members
+}
+
+func (res resource) removeMembers(remove map[string]bool) {
+	members, _ := res["members"].([]interface{})
+	kept := []interface{}{}
+	for _, member := range members {
+		if !remove[memberID(member)] {
+			kept = append(kept, member)
+		}
+	}
+	res["members"] = kept
+}
+
+func (s *Server) removeMemberEverywhere(userID string) {
+	for _, group := range s.groups {
+		group.removeMembers(map[string]bool{userID: true})
+	}
+}
+
+// render returns the resource as sent to clients. Users get their groups attribute computed from group membership.
+func (s *Server) render(res resource) resource {
+	if res.meta()["resourceType"] != "User" {
+		return res
+	}
+	out := resource{}
+	for k, v := range res {
+		out[k] = v
+	}
+	groups := []interface{}{}
+	var ids []string
+	for id := range s.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		group := s.groups[id]
+		members, _ := group["members"].([]interface{})
+		for _, member := range members {
+			if memberID(member) == res["id"] {
+				groups = append(groups, map[string]interface{}{"type": "direct", "value": id, "display": group["displayName"]})
+				break
+			}
+		}
+	}
+	out["groups"] = groups
+	return out
+}
+
+func (s *Server) write(w http.ResponseWriter, status int, res resource) {
+	header := http.Header{}
+	if version, ok := res.meta()["version"].(string); ok {
+		header.Set("ETag", version)
+	}
+	writeJSON(w, status, header, s.render(res))
+}
+
+func (res resource) meta() map[string]interface{} {
+	meta, _ := res["meta"].(map[string]interface{})
+	return meta
+}
+
+// touch updates the last modified time and bumps the version of the resource.
+func (res resource) touch() {
+	meta := res.meta()
+	if meta == nil {
+		return
+	}
+	version := 1
+	if v, ok := meta["version"].(string); ok {
+		version, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(v, `W/"`), `"`))
+	}
+	meta["lastModified"] = time.Now().UTC()
+	meta["version"] = fmt.Sprintf(`W/"%d"`, version+1)
+}
+
+// lookup returns the value of a simple or dotted attribute path, such as "userName" or "name.givenName".
+func (res resource) lookup(path string) interface{} {
+	var current interface{} = map[string]interface{}(res)
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[part]
+	}
+	return current
+}
+
+func memberID(member interface{}) string {
+	m, _ := member.(map[string]interface{})
+	id, _ := m["value"].(string)
+	return id
+}
+
+func memberIDs(value interface{}) []string {
+	values, _ := value.([]interface{})
+	ids := make([]string, 0, len(values))
+	for _, v := range values {
+		if id := memberID(v); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func unescape(value string) string {
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
+}
+
+func writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	writeJSON(w, status, nil, map[string]interface{}{
+		"schemas":  []string{errorSchema},
+		"scimType": scimType,
+		"detail":   detail,
+		"status":   strconv.Itoa(status),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, header http.Header, body interface{}) {
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
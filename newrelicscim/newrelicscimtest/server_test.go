@@ -0,0 +1,243 @@
+package newrelicscimtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/atilsensalduz/new-relic-scim-go-client/newrelicscim/newrelicscim"
+	"github.com/atilsensalduz/new-relic-scim-go-client/newrelicscim/newrelicscim/newrelicscimtest"
+)
+
+func newUser(userName string) newrelicscim.User {
+	return newrelicscim.User{
+		UserName: userName,
+		Emails:   []newrelicscim.Email{{Value: userName, Primary: true}},
+	}
+}
+
+func TestServer(t *testing.T) {
+	server := newrelicscimtest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+
+	// create
+	ids := map[string]string{}
+	for _, userName := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		user, err := newrelicscim.Result(client.CreateUser(ctx, newUser(userName)))
+		if err != nil {
+			t.Fatalf("CreateUser(%s): %v", userName, err)
+		}
+		if user.ID == "" || user.UserName != userName || user.ETag == "" {
+			t.Fatalf("CreateUser(%s) = %+v, want an ID, the userName and an ETag", userName, user)
+		}
+		ids[userName] = user.ID
+	}
+	if _, err := newrelicscim.Result(client.CreateUser(ctx, newUser("Alice@example.com"))); !errors.Is(err, newrelicscim.ErrConflict) {
+		t.Errorf("CreateUser of a duplicate userName: error = %v, want ErrConflict", err)
+	}
+
+	// filter
+	users, err := newrelicscim.Result(client.UserList(ctx, newrelicscim.WithFilter(newrelicscim.FilterEq("userName", "bob@example.com"))))
+	if err != nil {
+		t.Fatalf("UserList with eq filter: %v", err)
+	}
+	if users.TotalResults != 1 || len(users.Resources) != 1 || users.Resources[0].ID != ids["bob@example.com"] {
+		t.Errorf("UserList with eq filter = %+v, want only bob", users.Resources)
+	}
+	users, err = newrelicscim.Result(client.UserList(ctx, newrelicscim.WithFilter(newrelicscim.FilterStartsWith("userName", "ca"))))
+	if err != nil {
+		t.Fatalf("UserList with sw filter: %v", err)
+	}
+	if users.TotalResults != 1 || users.Resources[0].UserName != "carol@example.com" {
+		t.Errorf("UserList with sw filter = %+v, want only carol", users.Resources)
+	}
+
+	// paging
+	var paged []string
+	for startIndex := 1; ; startIndex += 2 {
+		page, err := newrelicscim.Result(client.UserListPage(ctx, startIndex, 2))
+		if err != nil {
+			t.Fatalf("UserListPage(%d, 2): %v", startIndex, err)
+		}
+		if page.TotalResults != 3 || page.StartIndex != startIndex {
+			t.Errorf("UserListPage(%d, 2): totalResults %d, startIndex %d", startIndex, page.TotalResults, page.StartIndex)
+		}
+		for _, user := range page.Resources {
+			paged = append(paged, user.UserName)
+		}
+		if len(page.Resources) < 2 {
+			break
+		}
+	}
+	if fmt.Sprint(paged) != "[alice@example.com bob@example.com carol@example.com]" {
+		t.Errorf("paged users = %v, want alice, bob and carol in order", paged)
+	}
+
+	// patch
+	aliceID := ids["alice@example.com"]
+	alice, err := newrelicscim.Result(client.DeactivateUser(ctx, aliceID))
+	if err != nil {
+		t.Fatalf("DeactivateUser: %v", err)
+	}
+	if alice.Active {
+		t.Error("DeactivateUser left the user active")
+	}
+	users, err = newrelicscim.Result(client.UserList(ctx, newrelicscim.WithActive(false)))
+	if err != nil {
+		t.Fatalf("UserList with active filter: %v", err)
+	}
+	if users.TotalResults != 1 || users.Resources[0].ID != aliceID {
+		t.Errorf("UserList of inactive users = %+v, want only alice", users.Resources)
+	}
+
+	// If-Match
+	current, err := newrelicscim.Result(client.GetUserByID(ctx, aliceID))
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	update := newUser("alice@example.com")
+	update.Title = "Engineer"
+	if _, err := newrelicscim.Result(client.UpdateUserIfMatch(ctx, aliceID, update, `W/"stale"`)); !errors.Is(err, newrelicscim.ErrPreconditionFailed) {
+		t.Errorf("UpdateUserIfMatch with a stale ETag: error = %v, want ErrPreconditionFailed", err)
+	}
+	updated, err := newrelicscim.Result(client.UpdateUserIfMatch(ctx, aliceID, update, current.ETag))
+	if err != nil {
+		t.Fatalf("UpdateUserIfMatch with the current ETag: %v", err)
+	}
+	if updated.Title != "Engineer" || updated.Active {
+		t.Errorf("UpdateUserIfMatch = %+v, want title Engineer and the user still inactive", updated)
+	}
+
+	// delete
+	if err := client.DeleteUser(ctx, aliceID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, err := newrelicscim.Result(client.GetUserByID(ctx, aliceID)); !errors.Is(err, newrelicscim.ErrNotFound) {
+		t.Errorf("GetUserByID after DeleteUser: error = %v, want ErrNotFound", err)
+	}
+	if err := client.DeleteUser(ctx, aliceID); !errors.Is(err, newrelicscim.ErrNotFound) {
+		t.Errorf("DeleteUser of a deleted user: error = %v, want ErrNotFound", err)
+	}
+}
+
+// memberIDs returns the sorted IDs of the members of a group.
+func memberIDs(t *testing.T, client *newrelicscim.Client, groupID string) []string {
+	t.Helper()
+	members, err := newrelicscim.Result(client.GetGroupMembers(context.Background(), groupID))
+	if err != nil {
+		t.Fatalf("GetGroupMembers: %v", err)
+	}
+	ids := []string{}
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestServerGroupMembers(t *testing.T) {
+	server := newrelicscimtest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+
+	var u []string
+	for i := 0; i < 4; i++ {
+		user, err := newrelicscim.Result(client.CreateUser(ctx, newUser(fmt.Sprintf("user%d@example.com", i))))
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		u = append(u, user.ID)
+	}
+
+	// group create
+	group, err := newrelicscim.Result(client.CreateGroup(ctx, "Engineering"))
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if group.ID == "" || group.DisplayName != "Engineering" {
+		t.Fatalf("CreateGroup = %+v, want an ID and the display name", group)
+	}
+	if got := memberIDs(t, client, group.ID); len(got) != 0 {
+		t.Errorf("members of a new group = %v, want none", got)
+	}
+
+	// members add
+	if _, err := newrelicscim.Result(client.AddUsersToGroup(ctx, group.ID, []string{u[0], u[1], u[2]})); err != nil {
+		t.Fatalf("AddUsersToGroup: %v", err)
+	}
+	if got, want := memberIDs(t, client, group.ID), []string{u[0], u[1], u[2]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("members after add = %v, want %v", got, want)
+	}
+
+	// members remove, sent as members[value eq "..."] paths
+	if _, err := newrelicscim.Result(client.RemoveUsersFromGroup(ctx, group.ID, []string{u[1]})); err != nil {
+		t.Fatalf("RemoveUsersFromGroup: %v", err)
+	}
+	if got, want := memberIDs(t, client, group.ID), []string{u[0], u[2]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("members after remove = %v, want %v", got, want)
+	}
+
+	// members replace
+	if _, err := newrelicscim.Result(client.ReplaceGroupMembers(ctx, group.ID, []string{u[3]})); err != nil {
+		t.Fatalf("ReplaceGroupMembers: %v", err)
+	}
+	if got, want := memberIDs(t, client, group.ID), []string{u[3]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("members after replace = %v, want %v", got, want)
+	}
+
+	// sync
+	result, err := newrelicscim.Result(client.SyncGroupMembers(ctx, group.ID, []string{u[0], u[1], u[0]}))
+	if err != nil {
+		t.Fatalf("SyncGroupMembers: %v", err)
+	}
+	if fmt.Sprint(result.Added, result.Removed) != fmt.Sprint([]string{u[0], u[1]}, []string{u[3]}) {
+		t.Errorf("SyncGroupMembers = %+v, want %v added and %v removed", result, []string{u[0], u[1]}, []string{u[3]})
+	}
+	if got, want := memberIDs(t, client, group.ID), []string{u[0], u[1]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("members after sync = %v, want %v", got, want)
+	}
+	result, err = newrelicscim.Result(client.SyncGroupMembers(ctx, group.ID, []string{u[1], u[0]}))
+	if err != nil || len(result.Added) != 0 || len(result.Removed) != 0 {
+		t.Errorf("SyncGroupMembers of an up to date group = %+v, %v, want no changes", result, err)
+	}
+
+	// removing a user removes it from every group
+	if err := client.DeleteUser(ctx, u[1]); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if got, want := memberIDs(t, client, group.ID), []string{u[0]}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("members after deleting a user = %v, want %v", got, want)
+	}
+}
+
+func TestServerCreateGroupWithMembers(t *testing.T) {
+	server := newrelicscimtest.NewServer()
+	defer server.Close()
+	client := server.NewClient()
+	ctx := context.Background()
+
+	var u []string
+	for i := 0; i < 2; i++ {
+		user, err := newrelicscim.Result(client.CreateUser(ctx, newUser(fmt.Sprintf("user%d@example.com", i))))
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		u = append(u, user.ID)
+	}
+
+	created, err := newrelicscim.Result(client.CreateGroupWithMembers(ctx, "Operations", u))
+	if err != nil {
+		t.Fatalf("CreateGroupWithMembers: %v", err)
+	}
+	if created.ID == "" || created.MembersPatched {
+		t.Errorf("CreateGroupWithMembers = %+v, want an ID and the members sent with the create", created)
+	}
+	if got := memberIDs(t, client, created.ID); fmt.Sprint(got) != fmt.Sprint(u) {
+		t.Errorf("members = %v, want %v", got, u)
+	}
+}
@@ -0,0 +1,96 @@
+package newrelicscim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchOpType is the operation of a SCIM patch operation. RFC 7644 defines the operations in lowercase, which is how
+// the constants serialize.
+type PatchOpType string
+
+// Patch operations accepted by PatchOperation.
+const (
+	OpAdd     PatchOpType = "add"
+	OpRemove  PatchOpType = "remove"
+	OpReplace PatchOpType = "replace"
+)
+
+// valid reports whether the operation is one of OpAdd, OpRemove or OpReplace.
+func (o PatchOpType) valid() bool {
+	switch o {
+	case OpAdd, OpRemove, OpReplace:
+		return true
+	}
+	return false
+}
+
+// ParsePatchOpType converts an operation name, such as "add" or "Remove", into a PatchOpType. Names are matched
+// case-insensitively; any other name is an error.
+func ParsePatchOpType(s string) (PatchOpType, error) {
+	op := PatchOpType(strings.ToLower(s))
+	if !op.valid() {
+		return "", fmt.Errorf("invalid patch operation %q, must be one of %q, %q or %q", s, OpAdd, OpRemove, OpReplace)
+	}
+	return op, nil
+}
+
+// PatchOperation is a single SCIM patch operation, used by PatchUser and the group patch methods.
+//
+// It has the following fields:
+//   - Op: the operation to perform, one of OpAdd, OpReplace or OpRemove
+//   - Path: the attribute path the operation applies to, such as "active" or `emails[type eq "work"].value`
+//   - Value: the new value for add and replace operations, left nil for remove operations so that no value key is sent
+type PatchOperation struct {
+	Op    PatchOpType `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// AddOperation returns a patch operation adding value to the attribute at path.
+func AddOperation(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: OpAdd, Path: path, Value: value}
+}
+
+// ReplaceOperation returns a patch operation replacing the attribute at path with value.
+func ReplaceOperation(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: OpReplace, Path: path, Value: value}
+}
+
+// RemoveOperation returns a patch operation removing the attribute or values at path, such as a single non-primary
+// email with `emails[value eq "old@example.com"]`. Remove operations carry no value.
+func RemoveOperation(path string) PatchOperation {
+	return PatchOperation{Op: OpRemove, Path: path}
+}
+
+// PatchRequest is the body of a SCIM patch request.
+//
+// It has the following fields:
+//   - Schemas: a slice of strings containing the SCIM schema URIs of the request, defaulting to the PatchOp message schema
+//   - Operations: the patch operations to apply, in order
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// fill_defaults is a helper function that sets the Schemas field of a PatchRequest to the PatchOp message schema if it
+// is empty.
+func (pr *PatchRequest) fill_defaults() {
+
+	// setting default values
+	// if no values present
+	if len(pr.Schemas) == 0 {
+		pr.Schemas = []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"}
+	}
+}
+
+// validate checks that every operation of the request is one of OpAdd, OpRemove or OpReplace, so that a misspelled or
+// wrongly capitalized operation is reported before the request is sent.
+func (pr *PatchRequest) validate() error {
+	for i, op := range pr.Operations {
+		if !op.Op.valid() {
+			return fmt.Errorf("invalid patch operation %d: %q, must be one of %q, %q or %q", i, op.Op, OpAdd, OpRemove, OpReplace)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package newrelicscim
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGroupPatchJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body UpdateGroup
+		want string
+	}{
+		{
+			name: "add members",
+			body: UpdateGroup{Operations: []PatchOp{AddOperation("members", memberValues([]string{"1", "2"}))}},
+			want: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":"1"},{"value":"2"}]}]}`,
+		},
+		{
+			name: "replace display name",
+			body: UpdateGroup{Operations: []PatchOp{ReplaceOperation("displayName", "Engineering")}},
+			want: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"displayName","value":"Engineering"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.body.fill_defaults()
+			if err := tt.body.validate(); err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			got, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveOperationJSON(t *testing.T) {
+	got, err := json.Marshal(RemoveOperation(`emails[value eq "old@example.com"]`))
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	want := `{"op":"remove","path":"emails[value eq \"old@example.com\"]"}`
+	if string(got) != want {
+		t.Errorf("json.Marshal(RemoveOperation) = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package newrelicscim
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that limits how many requests a Client sends per second. The bucket holds at most
+// burst tokens and refills at rate tokens per second; every request takes one token.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a rateLimiter that allows rps requests per second with bursts of up to burst requests. The
+// bucket starts full.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until a token is available and takes it. It returns the context error if the context is done first.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// WithRateLimit limits the client to rps requests per second, allowing bursts of up to burst requests. Requests that
+// exceed the limit wait for their turn before they are sent, or fail with the context error if their context is done
+// first. Retries count against the limit as well. A rate of 0 or less disables the limit, which is the default.
+func WithRateLimit(rps float64, burst int) ClientOption {
+	return func(c *Client) {
+		if rps <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// RateLimit is the rate limit quota reported by the API in the X-RateLimit-* headers of a response.
+//
+// It has the following fields:
+//  - Limit: the number of requests allowed in the current window, from X-RateLimit-Limit
+//  - Remaining: the number of requests left in the current window, from X-RateLimit-Remaining
+//  - Reset: the time the current window ends, from X-RateLimit-Reset
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// parseRateLimit reads the X-RateLimit-* headers of a response. X-RateLimit-Reset is accepted both as a Unix timestamp
+// and as a number of seconds from now. It returns false if the response carries no X-RateLimit-Remaining header.
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	rateLimit := RateLimit{Remaining: remaining}
+	if limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit")); err == nil {
+		rateLimit.Limit = limit
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// Values this large cannot be a delay in seconds, so they are taken as a Unix timestamp.
+		if reset > 1e9 {
+			rateLimit.Reset = time.Unix(reset, 0)
+		} else {
+			rateLimit.Reset = time.Now().Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return rateLimit, true
+}
+
+// recordRateLimit stores the rate limit reported by a response, if any, for LastRateLimit.
+func (c *Client) recordRateLimit(header http.Header) {
+	rateLimit, ok := parseRateLimit(header)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	c.lastRateLimit = &rateLimit
+	c.mu.Unlock()
+}
+
+// LastRateLimit returns the rate limit reported by the most recent response that carried X-RateLimit-* headers, so
+// callers can slow down before the quota runs out instead of waiting for a 429. It returns false if no response has
+// reported a rate limit yet. It is safe to call while other requests are in flight.
+func (c *Client) LastRateLimit() (RateLimit, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastRateLimit == nil {
+		return RateLimit{}, false
+	}
+	return *c.lastRateLimit, true
+}
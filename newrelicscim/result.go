@@ -0,0 +1,41 @@
+package newrelicscim
+
+import "strconv"
+
+// ErrorResponseType is the set of SCIM error message types returned by the client methods.
+type ErrorResponseType interface {
+	UserErrorResponse | GroupErrorResponse | ErrorResponse
+}
+
+// Result collapses the three values returned by the client methods into a result and a single error, so that callers
+// only have to check one value:
+//
+//	user, err := newrelicscim.Result(client.GetUserByID(ctx, userID))
+//	if err != nil {
+//		var apiErr *newrelicscim.APIError
+//		if errors.As(err, &apiErr) {
+//			log.Printf("status %d, scimType %q: %s", apiErr.StatusCode, apiErr.ScimType, apiErr.Detail)
+//		}
+//	}
+//
+// If err is not nil, it is returned as is; for error responses from the API it is an *APIError carrying the SCIM error
+// detail, the response headers and the body. If the error response is set although err is nil, which happens when a
+// 2xx response carries a SCIM error message, it is returned as an *APIError built from the decoded error response: it
+// has StatusCode, ScimType and Detail, but a nil Header and Body.
+//
+// The three-value methods are the stable API of the package and are not deprecated; Result is an optional convenience
+// on top of them, and both forms will keep working.
+func Result[T any, E ErrorResponseType](result T, errorResponse E, err error) (T, error) {
+	if err != nil {
+		return result, err
+	}
+	if r := ErrorResponse(errorResponse); r.Status != "" || isErrorResponse(r.Schemas) {
+		statusCode, _ := strconv.Atoi(r.Status)
+		return result, &APIError{
+			StatusCode: statusCode,
+			ScimType:   r.ScimType,
+			Detail:     r.Detail,
+		}
+	}
+	return result, nil
+}
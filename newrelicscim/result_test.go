@@ -0,0 +1,26 @@
+package newrelicscim
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResult(t *testing.T) {
+	sentErr := &APIError{StatusCode: 404, Body: []byte(`{}`)}
+	if _, err := Result(UserResponse{}, UserErrorResponse{}, sentErr); err != sentErr {
+		t.Errorf("Result returned %v, want the error as is", err)
+	}
+
+	_, err := Result(UserResponse{}, UserErrorResponse{Status: "409", ScimType: "uniqueness", Detail: "exists"}, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrConflict) {
+		t.Fatalf("Result error = %v, want an *APIError matching ErrConflict", err)
+	}
+	if apiErr.ScimType != "uniqueness" || apiErr.Detail != "exists" || apiErr.Header != nil || apiErr.Body != nil {
+		t.Errorf("Result error = %+v, want the decoded fields and no Header or Body", apiErr)
+	}
+
+	if user, err := Result(UserResponse{ID: "1"}, UserErrorResponse{}, nil); err != nil || user.ID != "1" {
+		t.Errorf("Result = %+v, %v, want the user and no error", user, err)
+	}
+}
@@ -0,0 +1,79 @@
+package newrelicscim
+
+import "context"
+
+// SimpleClient is a convenience layer over Client for one-off scripts. Its methods call the Client methods of the same
+// name with context.Background(), so they are still bound by the RequestTimeout of the client, and collapse their
+// results with Result into a value and a single error. Programs that need cancellation or per-call deadlines should use
+// the Client methods directly.
+type SimpleClient struct {
+	client *Client
+}
+
+// Simple returns a SimpleClient that calls c without a caller-provided context:
+//
+//	client := newrelicscim.NewClient(apiToken, newrelicscim.WithRequestTimeout(30*time.Second))
+//	user, err := client.Simple().GetUserByName("john.doe@example.com")
+func (c *Client) Simple() *SimpleClient {
+	return &SimpleClient{client: c}
+}
+
+// GetUserByID calls Client.GetUserByID with a background context.
+func (s *SimpleClient) GetUserByID(userID string) (UserResponse, error) {
+	return Result(s.client.GetUserByID(context.Background(), userID))
+}
+
+// GetUserByName calls Client.GetUserByName with a background context.
+func (s *SimpleClient) GetUserByName(userName string) (UserResponse, error) {
+	return Result(s.client.GetUserByName(context.Background(), userName))
+}
+
+// ListUsers calls Client.UserListAll with a background context.
+func (s *SimpleClient) ListUsers(opts ...ListOption) ([]UserResource, error) {
+	return Result(s.client.UserListAll(context.Background(), opts...))
+}
+
+// CreateUser calls Client.CreateUser with a background context.
+func (s *SimpleClient) CreateUser(user User) (UserResponse, error) {
+	return Result(s.client.CreateUser(context.Background(), user))
+}
+
+// UpdateUser calls Client.UpdateUser with a background context.
+func (s *SimpleClient) UpdateUser(userID string, user User) (UserResponse, error) {
+	return Result(s.client.UpdateUser(context.Background(), userID, user))
+}
+
+// DeleteUser calls Client.DeleteUser with a background context.
+func (s *SimpleClient) DeleteUser(userID string) error {
+	return s.client.DeleteUser(context.Background(), userID)
+}
+
+// FindGroupByName calls Client.FindGroupByName with a background context.
+func (s *SimpleClient) FindGroupByName(groupName string) (GroupResponse, error) {
+	return Result(s.client.FindGroupByName(context.Background(), groupName))
+}
+
+// ListGroups calls Client.GroupListAll with a background context.
+func (s *SimpleClient) ListGroups(opts ...ListOption) ([]GroupResource, error) {
+	return Result(s.client.GroupListAll(context.Background(), opts...))
+}
+
+// CreateGroup calls Client.CreateGroup with a background context.
+func (s *SimpleClient) CreateGroup(groupName string) (GroupResponse, error) {
+	return Result(s.client.CreateGroup(context.Background(), groupName))
+}
+
+// DeleteGroup calls Client.DeleteGroup with a background context.
+func (s *SimpleClient) DeleteGroup(groupID string) error {
+	return s.client.DeleteGroup(context.Background(), groupID)
+}
+
+// AddUserToGroup calls Client.AddUserToGroup with a background context.
+func (s *SimpleClient) AddUserToGroup(groupID string, userID string) (GroupResponse, error) {
+	return Result(s.client.AddUserToGroup(context.Background(), groupID, userID))
+}
+
+// RemoveUserFromGroup calls Client.RemoveUserFromGroup with a background context.
+func (s *SimpleClient) RemoveUserFromGroup(groupID string, userID string) (GroupResponse, error) {
+	return Result(s.client.RemoveUserFromGroup(context.Background(), groupID, userID))
+}
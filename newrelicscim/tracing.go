@@ -0,0 +1,90 @@
+package newrelicscim
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Tracer starts spans for the requests of a Client, so that SCIM calls show up in the traces of the calling program.
+// It is an interface rather than a dependency on a tracing library; an adapter for an OpenTelemetry TracerProvider
+// takes a few lines:
+//
+//	type otelTracer struct{ tracer trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, newrelicscim.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ span trace.Span }
+//
+//	func (s otelSpan) SetAttributes(method, path string, status int) {
+//		s.span.SetAttributes(
+//			attribute.String("http.request.method", method),
+//			attribute.String("url.path", path),
+//			attribute.Int("http.response.status_code", status),
+//		)
+//	}
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.span.RecordError(err)
+//			s.span.SetStatus(codes.Error, err.Error())
+//		}
+//		s.span.End()
+//	}
+//
+//	client := newrelicscim.NewClient(apiToken, newrelicscim.WithTracer(otelTracer{provider.Tracer("newrelicscim")}))
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced request started by a Tracer.
+//
+// It has the following methods:
+//  - SetAttributes: called once before End with the HTTP method, the URL path and the final response status code, or
+//    0 if no response was received
+//  - End: called when the request, including its retries, has completed, with the error it failed with, if any
+type Span interface {
+	SetAttributes(method, path string, status int)
+	End(err error)
+}
+
+// WithTracer sets the Tracer that starts a span for every call, named after the method and endpoint, such as
+// "SCIM PATCH Groups". Retries of a call are part of its span. Without a Tracer no spans are created.
+func WithTracer(tracer Tracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// startSpan starts a span for the request if a Tracer is configured. It returns the request carrying the context of
+// the span and a function that ends the span with the outcome of the call.
+func (c *Client) startSpan(req *http.Request) (*http.Request, func(RawResponse, error)) {
+	if c.tracer == nil {
+		return req, func(RawResponse, error) {}
+	}
+	ctx, span := c.tracer.Start(req.Context(), "SCIM "+req.Method+" "+spanEndpoint(req.URL.Path))
+	return req.WithContext(ctx), func(raw RawResponse, err error) {
+		status := raw.StatusCode
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			status = apiErr.StatusCode
+		}
+		span.SetAttributes(req.Method, req.URL.Path, status)
+		span.End(err)
+	}
+}
+
+// spanEndpoint returns the endpoint of a request path, such as "Users" for ".../scim/v2/Users/123", so that span names
+// do not contain resource IDs.
+func spanEndpoint(path string) string {
+	for _, endpoint := range []string{userPath, groupPath, bulkPath, serviceProviderConfigPath, resourceTypesPath, schemasPath} {
+		if strings.Contains(path, "/"+endpoint) {
+			return endpoint
+		}
+	}
+	return path
+}
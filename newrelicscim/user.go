@@ -4,68 +4,147 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strconv"
+	"strings"
 )
 
 const userPath = "Users"
 
+// maxPageCount is the largest page size accepted by the New Relic SCIM API for list requests.
+const maxPageCount = 100
+
+// User is a user as sent by CreateUser and UpdateUser. Active is a pointer so that leaving it nil can be told apart from
+// explicitly deactivating the user with Bool(false). A nil Active is sent as the DefaultActive of the client when the
+// user is created, and left out when the user is updated, so that an update does not reactivate a deactivated user.
 type User struct {
-	Schemas  []string `json:"schemas"`
-	UserName string   `json:"userName"`
-	Name     Name     `json:"name"`
-	Emails   []Email  `json:"emails"`
-	Active   bool     `json:"active"`
-	Timezone string   `json:"timezone"`
+	Schemas      []string      `json:"schemas"`
+	ExternalID   string        `json:"externalId,omitempty"`
+	UserName     string        `json:"userName"`
+	Name         Name          `json:"name"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	Title        string        `json:"title,omitempty"`
+	Emails       []Email       `json:"emails,omitempty"`
+	PhoneNumbers []PhoneNumber `json:"phoneNumbers,omitempty"`
+	Active       *bool         `json:"active,omitempty"`
+	Timezone     string        `json:"timezone,omitempty"`
+}
+
+// Bool returns a pointer to v, for setting optional fields such as User.Active:
+//
+//	user := newrelicscim.User{UserName: "john.doe@example.com", Active: newrelicscim.Bool(false)}
+func Bool(v bool) *bool {
+	return &v
+}
+
+// MarshalJSON encodes the user, leaving out the name when all of its fields are empty. encoding/json cannot omit an
+// empty struct with omitempty, and some SCIM servers reject an empty name object.
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	out := struct {
+		user
+		Name *Name `json:"name,omitempty"`
+	}{user: user(u)}
+	if u.Name != (Name{}) {
+		out.Name = &u.Name
+	}
+	return json.Marshal(out)
 }
 
 type Name struct {
-	FamilyName string `json:"familyName"`
-	GivenName  string `json:"givenName"`
+	FamilyName      string `json:"familyName"`
+	GivenName       string `json:"givenName"`
+	MiddleName      string `json:"middleName,omitempty"`
+	HonorificPrefix string `json:"honorificPrefix,omitempty"`
+	Formatted       string `json:"formatted,omitempty"`
 }
 
 type Email struct {
 	Primary bool   `json:"primary"`
 	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
 }
 
-func (u *User) fill_defaults() {
+type PhoneNumber struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// validate checks the user for problems that the SCIM API would reject, such as a missing userName or more than one
+// primary email, so they are reported before the request is sent. All problems are returned in one *ValidationError.
+func (u *User) validate() error {
+	var validationErr ValidationError
+	if strings.TrimSpace(u.UserName) == "" {
+		validationErr.add("userName", ErrMissingUserName)
+	}
+	primaries := 0
+	for _, email := range u.Emails {
+		if email.Primary {
+			primaries++
+		}
+	}
+	switch {
+	case primaries == 0:
+		validationErr.add("emails", ErrMissingPrimaryEmail)
+	case primaries > 1:
+		validationErr.add("emails", fmt.Errorf("%w: %d emails are marked primary", ErrMultiplePrimaryEmails, primaries))
+	}
+	return validationErr.errOrNil()
+}
+
+// fill_defaults sets the schemas and timezone of the user if they are missing, and sets Active to active if it is nil.
+// Updates pass a nil active, so that the active flag of the existing user is left alone.
+func (u *User) fill_defaults(timezone string, active *bool) {
 
 	// setting default values
 	// if no values present
 	if len(u.Schemas) == 0 {
 		u.Schemas = []string{"urn:ietf:params:scim:schemas:core:2.0:User"}
 	}
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
 	if u.Timezone == "" {
-		u.Timezone = "Europe/Istanbul"
+		u.Timezone = timezone
 	}
-	if !u.Active {
-		u.Active = true
+	if u.Active == nil {
+		u.Active = active
 	}
 }
 
 type UserResponse struct {
-	Schemas    []string `json:"schemas"`
-	ID         string   `json:"id"`
-	ExternalID string   `json:"externalId"`
-	UserName   string   `json:"userName"`
-	Name       struct {
-		FamilyName string `json:"familyName"`
-		GivenName  string `json:"givenName"`
-	} `json:"name"`
-	Emails []struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	ExternalID  string   `json:"externalId"`
+	UserName    string   `json:"userName"`
+	Name        Name     `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Title       string   `json:"title"`
+	Emails      []struct {
 		Value   string `json:"value"`
 		Primary bool   `json:"primary"`
+		Type    string `json:"type"`
 	} `json:"emails"`
-	Timezone string `json:"timezone"`
-	Active   bool   `json:"active"`
-	Meta     struct {
-		ResourceType string    `json:"resourceType"`
-		Created      time.Time `json:"created"`
-		LastModified time.Time `json:"lastModified"`
-	} `json:"meta"`
-	Groups []interface{} `json:"groups"`
+	PhoneNumbers []PhoneNumber  `json:"phoneNumbers"`
+	Timezone     string         `json:"timezone"`
+	Active       bool           `json:"active"`
+	Meta         Meta           `json:"meta"`
+	Groups       []UserGroupRef `json:"groups"`
+	// ETag is the version of the user taken from the ETag response header or, without it, from Meta.Version.
+	ETag string `json:"-"`
+	// Recovered is set when CreateUser returned an existing user, looked up by userName after a retried create
+	// conflicted, instead of a user created by the call.
+	Recovered bool `json:"-"`
+}
+
+// UserGroupRef is a reference to a group the user is a member of.
+type UserGroupRef struct {
+	Type    string `json:"type"`
+	Value   string `json:"value"`
+	Display string `json:"display"`
+	Ref     string `json:"$ref"`
 }
 
 type UserErrorResponse struct {
@@ -76,35 +155,52 @@ type UserErrorResponse struct {
 }
 
 type UsersResponse struct {
-	TotalResults int      `json:"totalResults"`
-	Schemas      []string `json:"schemas"`
-	Resources    []struct {
-		Schemas    []string    `json:"schemas"`
-		ID         string      `json:"id"`
-		ExternalID interface{} `json:"externalId"`
-		UserName   string      `json:"userName"`
-		Name       struct {
-			FamilyName string `json:"familyName"`
-			GivenName  string `json:"givenName"`
-		} `json:"name"`
-		Emails []struct {
-			Value   string `json:"value"`
-			Primary bool   `json:"primary"`
-		} `json:"emails"`
-		Timezone string `json:"timezone"`
-		Active   bool   `json:"active"`
-		Meta     struct {
-			ResourceType string    `json:"resourceType"`
-			Created      time.Time `json:"created"`
-			LastModified time.Time `json:"lastModified"`
-		} `json:"meta"`
-		Groups []struct {
-			Type  string `json:"type"`
-			Value string `json:"value"`
-		} `json:"groups"`
-	} `json:"Resources"`
+	TotalResults int            `json:"totalResults"`
+	ItemsPerPage int            `json:"itemsPerPage"`
+	StartIndex   int            `json:"startIndex"`
+	Schemas      []string       `json:"schemas"`
+	Resources    []UserResource `json:"Resources"`
+	// Raw is the undecoded response, for debugging responses that do not decode as expected.
+	Raw RawResponse `json:"-"`
+}
+
+// UserResource is a single user entry in a UsersResponse list.
+type UserResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	ExternalID  interface{} `json:"externalId"`
+	UserName    string      `json:"userName"`
+	Name        Name        `json:"name"`
+	DisplayName string      `json:"displayName"`
+	Title       string      `json:"title"`
+	Emails      []struct {
+		Value   string `json:"value"`
+		Primary bool   `json:"primary"`
+		Type    string `json:"type"`
+	} `json:"emails"`
+	PhoneNumbers []PhoneNumber  `json:"phoneNumbers"`
+	Timezone     string         `json:"timezone"`
+	Active       bool           `json:"active"`
+	Meta         Meta           `json:"meta"`
+	Groups       []UserGroupRef `json:"groups"`
 }
 
+// newRelicUserSchema is the New Relic user extension schema URN. It must match the json tag of
+// UserTypeBody.UrnIetfParamsScimSchemasExtensionNewrelic21User.
+const newRelicUserSchema = "urn:ietf:params:scim:schemas:extension:newrelic:2.1:User"
+
+// UserTypeBody is the body ChangeUserType sends to set the New Relic user type. After fill_defaults it marshals to
+// exactly the payload New Relic expects, with the user type nested under the extension schema URN:
+//
+//	{
+//	  "schemas": [
+//	    "urn:ietf:params:scim:schemas:core:2.0:User",
+//	    "urn:ietf:params:scim:schemas:extension:newrelic:2.1:User"
+//	  ],
+//	  "urn:ietf:params:scim:schemas:extension:newrelic:2.1:User": {
+//	    "nrUserType": "Full User"
+//	  }
+//	}
 type UserTypeBody struct {
 	Schemas                                         []string `json:"schemas"`
 	UrnIetfParamsScimSchemasExtensionNewrelic21User struct {
@@ -117,24 +213,53 @@ func (u *UserTypeBody) fill_defaults() {
 	// setting default values
 	// if no values present
 	if len(u.Schemas) == 0 {
-		u.Schemas = []string{"urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:newrelic:2.0:User"}
+		u.Schemas = []string{"urn:ietf:params:scim:schemas:core:2.0:User", newRelicUserSchema}
 	}
 }
 
-func (c *Client) UserList(ctx context.Context) (usersResponse UsersResponse, userErrorResponse UserErrorResponse, err error) {
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, userPath)
-	req, err := http.NewRequest("Get", fullUrl, nil)
+// UserList retrieves the first page of users from the New Relic SCIM API using the API's default page size.
+func (c *Client) UserList(ctx context.Context, opts ...ListOption) (usersResponse UsersResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.UserListPage(ctx, 0, 0, opts...)
+}
+
+// UserListPage retrieves a single page of users from the New Relic SCIM API.
+//
+// startIndex is the 1-based index of the first user to return and count is the maximum number of users in the page.
+// A value lower than 1 for either argument leaves the parameter out of the request so the API default is used. The
+// list options, such as WithAttributes, are added to the query.
+func (c *Client) UserListPage(ctx context.Context, startIndex int, count int, opts ...ListOption) (usersResponse UsersResponse, userErrorResponse UserErrorResponse, err error) {
+	fullUrl := c.resourceURL(userPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
 		return usersResponse, userErrorResponse, err
 	}
-	resp, err := c.doRequest(req)
-	if err != nil {
+	q := req.URL.Query()
+	if startIndex > 0 {
+		q.Add("startIndex", strconv.Itoa(startIndex))
+	}
+	if count > 0 {
+		q.Add("count", strconv.Itoa(count))
+	}
+	if err := applyListOptions(q, opts); err != nil {
 		return usersResponse, userErrorResponse, err
 	}
+	req.URL.RawQuery = q.Encode()
+
+	raw, err := c.doRequestRaw(req)
+	if err != nil {
+		return usersResponse, UserErrorResponse(errorResponseOf(err)), err
+	}
+	usersResponse.Raw = raw
+	resp := raw.Body
 	if err := json.Unmarshal(resp, &usersResponse); err != nil {
 		return usersResponse, userErrorResponse, err
 	}
-	if usersResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	resourceTypes := make([]string, len(usersResponse.Resources))
+	for i, user := range usersResponse.Resources {
+		resourceTypes[i] = user.Meta.ResourceType
+	}
+	c.checkResourceTypes(req, ResourceTypeUser, resourceTypes)
+	if isErrorResponse(usersResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
 			return usersResponse, userErrorResponse, err
 		}
@@ -144,20 +269,139 @@ func (c *Client) UserList(ctx context.Context) (usersResponse UsersResponse, use
 	return usersResponse, userErrorResponse, nil
 }
 
+// UserListAll retrieves every user from the New Relic SCIM API by requesting pages of maxPageCount users until
+// TotalResults users have been collected. The context is checked between page fetches.
+func (c *Client) UserListAll(ctx context.Context, opts ...ListOption) (users []UserResource, userErrorResponse UserErrorResponse, err error) {
+	startIndex := 1
+	for {
+		if err := ctx.Err(); err != nil {
+			return users, userErrorResponse, err
+		}
+		usersResponse, userErrorResponse, err := c.UserListPage(ctx, startIndex, maxPageCount, opts...)
+		if err != nil || userErrorResponse.Status != "" {
+			return users, userErrorResponse, err
+		}
+		users = append(users, usersResponse.Resources...)
+		if len(usersResponse.Resources) == 0 || len(users) >= usersResponse.TotalResults {
+			return users, userErrorResponse, nil
+		}
+		startIndex += len(usersResponse.Resources)
+	}
+}
+
+// UserIterator walks through the users of a list request one at a time, fetching pages of maxPageCount users only as
+// they are needed, so that large accounts can be processed without holding every user in memory. Create one with
+// Client.UserIterator.
+//
+// Stopping early is safe: no request is in flight between calls to Next, so there is nothing to cancel or close.
+type UserIterator struct {
+	client     *Client
+	ctx        context.Context
+	opts       []ListOption
+	page       []UserResource
+	index      int
+	startIndex int
+	fetched    int
+	done       bool
+	err        error
+}
+
+// UserIterator returns an iterator over the users matching the list options:
+//
+//	it := client.UserIterator(ctx, newrelicscim.WithActive(true))
+//	for it.Next() {
+//		user := it.User()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+func (c *Client) UserIterator(ctx context.Context, opts ...ListOption) *UserIterator {
+	return &UserIterator{client: c, ctx: ctx, opts: opts, startIndex: 1}
+}
+
+// Next advances to the next user, fetching the next page when the current one is used up. It returns false when
+// there are no more users or a request fails; Err tells the two apart.
+func (it *UserIterator) Next() bool {
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	if it.done {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		it.done = true
+		return false
+	}
+	usersResponse, err := Result(it.client.UserListPage(it.ctx, it.startIndex, maxPageCount, it.opts...))
+	if err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+	it.page, it.index = usersResponse.Resources, 0
+	it.startIndex += len(it.page)
+	it.fetched += len(it.page)
+	if len(it.page) == 0 || it.fetched >= usersResponse.TotalResults {
+		it.done = true
+	}
+	return len(it.page) > 0
+}
+
+// User returns the current user. It is only valid after a call to Next returned true.
+func (it *UserIterator) User() UserResource {
+	return it.page[it.index]
+}
+
+// Err returns the error that stopped the iteration, or nil if it ran through every user.
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// CountUsers returns the number of users matching the list options, such as WithActive, without fetching them. It
+// requests a single page of one user with only its id attribute and reads TotalResults.
+func (c *Client) CountUsers(ctx context.Context, opts ...ListOption) (count int, userErrorResponse UserErrorResponse, err error) {
+	opts = append(opts[:len(opts):len(opts)], WithAttributes("id"))
+	usersResponse, userErrorResponse, err := c.UserListPage(ctx, 1, 1, opts...)
+	if err != nil || userErrorResponse.Status != "" {
+		return count, userErrorResponse, err
+	}
+	return usersResponse.TotalResults, userErrorResponse, nil
+}
+
+// ActiveUserCounts is the result of UserCounts.
+type ActiveUserCounts struct {
+	Active   int
+	Inactive int
+}
+
+// UserCounts returns the number of active and of inactive users, for license reporting, with two CountUsers requests
+// filtered on the active attribute instead of a scan of every user.
+func (c *Client) UserCounts(ctx context.Context) (counts ActiveUserCounts, userErrorResponse UserErrorResponse, err error) {
+	counts.Active, userErrorResponse, err = c.CountUsers(ctx, WithActive(true))
+	if err != nil || userErrorResponse.Status != "" {
+		return counts, userErrorResponse, err
+	}
+	counts.Inactive, userErrorResponse, err = c.CountUsers(ctx, WithActive(false))
+	return counts, userErrorResponse, err
+}
+
 func (c *Client) GetUserByID(ctx context.Context, userID string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, userPath, userID)
-	req, err := http.NewRequest("GET", fullUrl, nil)
+	fullUrl := c.resourceURL(userPath, userID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
 		return userResponse, userErrorResponse, err
 	}
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return userResponse, userErrorResponse, err
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &userResponse); err != nil {
 		return userResponse, userErrorResponse, err
 	}
-	if userResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	userResponse.ETag = etag(header, userResponse.Meta.Version)
+	if isErrorResponse(userResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
 			return userResponse, userErrorResponse, err
 		}
@@ -167,61 +411,116 @@ func (c *Client) GetUserByID(ctx context.Context, userID string) (userResponse U
 	return userResponse, userErrorResponse, nil
 }
 
-func (c *Client) GetUserByName(ctx context.Context, userName string) (usersResponse UsersResponse, userErrorResponse UserErrorResponse, err error) {
+// GetUserGroups returns the groups the user with the given ID is a member of, read from the groups attribute of the
+// user, so answering the question does not require scanning every group.
+func (c *Client) GetUserGroups(ctx context.Context, userID string) (groups []UserGroupRef, userErrorResponse UserErrorResponse, err error) {
+	userResponse, userErrorResponse, err := c.GetUserByID(ctx, userID)
+	if err != nil || userErrorResponse.Status != "" {
+		return groups, userErrorResponse, err
+	}
+	return userResponse.Groups, userErrorResponse, nil
+}
+
+// GetUserByName retrieves the user whose userName matches the given name.
+//
+// The filter query returns a list envelope, so the first user in it is returned. If no user matches, an error wrapping
+// ErrUserNotFound is returned.
+func (c *Client) GetUserByName(ctx context.Context, userName string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
 
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, userPath)
+	fullUrl := c.resourceURL(userPath)
 
-	req, err := http.NewRequest("GET", fullUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullUrl, nil)
 	if err != nil {
-		return usersResponse, userErrorResponse, err
+		return userResponse, userErrorResponse, err
 	}
 	q := req.URL.Query()
-	filter := fmt.Sprintf(`userName eq "%s"`, userName)
-	fmt.Println(filter)
+	filter := FilterEq("userName", userName)
 	q.Add("filter", filter)
 	req.URL.RawQuery = q.Encode()
 
-	fmt.Println(req.URL.String())
-
 	resp, err := c.doRequest(req)
 	if err != nil {
-		return usersResponse, userErrorResponse, err
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
 	}
-	if err := json.Unmarshal(resp, &usersResponse); err != nil {
-		return usersResponse, userErrorResponse, err
+	var listResponse struct {
+		TotalResults int            `json:"totalResults"`
+		Schemas      []string       `json:"schemas"`
+		Resources    []UserResponse `json:"Resources"`
+	}
+	if err := json.Unmarshal(resp, &listResponse); err != nil {
+		return userResponse, userErrorResponse, err
 	}
 
-	if usersResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	if isErrorResponse(listResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
-			return usersResponse, userErrorResponse, err
+			return userResponse, userErrorResponse, err
 		}
-
+		return userResponse, userErrorResponse, nil
+	}
+	if listResponse.TotalResults == 0 || len(listResponse.Resources) == 0 {
+		return userResponse, userErrorResponse, fmt.Errorf("user %q: %w", userName, ErrUserNotFound)
 	}
 
-	return usersResponse, userErrorResponse, nil
+	return listResponse.Resources[0], userErrorResponse, nil
 }
 
+// CreateUser creates a user. If a retried create request fails with a 409 Conflict, the earlier attempt most likely
+// created the user before its response was lost, so the user with the same userName is looked up and returned instead
+// of the error, with Recovered set. A user that already existed before the first attempt is returned the same way, so
+// callers that must tell the two apart should check Recovered. New Relic does not support idempotency keys; for creates
+// that must be safe to repeat across runs, use UpsertUser.
 func (c *Client) CreateUser(ctx context.Context, user User) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.createUser(ctx, user, false)
+}
 
-	fullUrl := fmt.Sprintf("%s%s", c.BaseUrl, userPath)
-	user.fill_defaults()
+// CreateUserIfNoneMatch creates a user like CreateUser, but sends If-None-Match: * so that a server supporting
+// conditional requests refuses to create a user that already exists. The refusal, a 412 response, is returned as a
+// *UserExistsError, which matches ErrConflict like a duplicate userName and unwraps to the *APIError of the response.
+// Servers that ignore the header behave like CreateUser.
+func (c *Client) CreateUserIfNoneMatch(ctx context.Context, user User) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.createUser(ctx, user, true)
+}
+
+// createUser sends the POST request for CreateUser and CreateUserIfNoneMatch, setting If-None-Match: * when
+// ifNoneMatch is true.
+func (c *Client) createUser(ctx context.Context, user User, ifNoneMatch bool) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+
+	fullUrl := c.resourceURL(userPath)
+	user.fill_defaults(c.DefaultTimezone, Bool(c.DefaultActive))
+	if err := user.validate(); err != nil {
+		return userResponse, userErrorResponse, err
+	}
 	//Encode the data
 	postBody, _ := json.Marshal(user)
 	responseBody := bytes.NewBuffer(postBody)
 
-	req, err := http.NewRequest("POST", fullUrl, responseBody)
+	req, err := http.NewRequestWithContext(withRetryableCreate(ctx), http.MethodPost, fullUrl, responseBody)
 	if err != nil {
 		return userResponse, userErrorResponse, err
 	}
+	if ifNoneMatch {
+		req.Header.Set("If-None-Match", "*")
+	}
 
-	resp, err := c.doRequest(req)
-	if err != nil {
+	resp, header, err := c.doRequestWithHeader(req)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Retried && (apiErr.StatusCode == http.StatusConflict || ifNoneMatch && apiErr.StatusCode == http.StatusPreconditionFailed) {
+		c.debugf("scim create of user %q conflicted on retry, returning the existing user", user.UserName)
+		userResponse, userErrorResponse, err = c.GetUserByName(ctx, user.UserName)
+		userResponse.Recovered = err == nil && userErrorResponse.Status == ""
 		return userResponse, userErrorResponse, err
 	}
+	if ifNoneMatch && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed {
+		return userResponse, UserErrorResponse(errorResponseOf(err)), &UserExistsError{UserName: user.UserName, Err: apiErr}
+	}
+	if err != nil {
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
+	}
 	if err := json.Unmarshal(resp, &userResponse); err != nil {
 		return userResponse, userErrorResponse, err
 	}
-	if userResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	userResponse.ETag = etag(header, userResponse.Meta.Version)
+	if isErrorResponse(userResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
 			return userResponse, userErrorResponse, err
 		}
@@ -230,26 +529,46 @@ func (c *Client) CreateUser(ctx context.Context, user User) (userResponse UserRe
 }
 
 func (c *Client) UpdateUser(ctx context.Context, userID string, user User) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.updateUser(ctx, userID, user, "")
+}
+
+// UpdateUserIfMatch replaces a user like UpdateUser, but only if the user's current version still matches the given
+// ETag, as returned in UserResponse.ETag. If the user was changed in the meantime, the API responds with 412 and an
+// error wrapping ErrPreconditionFailed is returned.
+func (c *Client) UpdateUserIfMatch(ctx context.Context, userID string, user User, etag string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.updateUser(ctx, userID, user, etag)
+}
 
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, userPath, userID)
+// updateUser sends the PUT request for UpdateUser and UpdateUserIfMatch, setting the If-Match header when ifMatch is
+// not empty.
+func (c *Client) updateUser(ctx context.Context, userID string, user User, ifMatch string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+
+	fullUrl := c.resourceURL(userPath, userID)
 	//Encode the data
-	user.fill_defaults()
+	user.fill_defaults(c.DefaultTimezone, nil)
+	if err := user.validate(); err != nil {
+		return userResponse, userErrorResponse, err
+	}
 	postBody, _ := json.Marshal(user)
 	responseBody := bytes.NewBuffer(postBody)
 
-	req, err := http.NewRequest("PUT", fullUrl, responseBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fullUrl, responseBody)
 	if err != nil {
 		return userResponse, userErrorResponse, err
 	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
 
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return userResponse, userErrorResponse, err
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &userResponse); err != nil {
 		return userResponse, userErrorResponse, err
 	}
-	if userResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	userResponse.ETag = etag(header, userResponse.Meta.Version)
+	if isErrorResponse(userResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
 			return userResponse, userErrorResponse, err
 		}
@@ -259,22 +578,141 @@ func (c *Client) UpdateUser(ctx context.Context, userID string, user User) (user
 	return userResponse, userErrorResponse, nil
 }
 
-func (c *Client) DeleteUser(ctx context.Context, userID string) (err error) {
+// UpsertUserResponse is the result of UpsertUser. Created reports whether the user was created rather than updated.
+type UpsertUserResponse struct {
+	UserResponse
+	Created bool
+}
+
+// UpsertUser creates the user if no user with the same userName exists, and otherwise replaces the existing user with
+// UpdateUser. If the create fails with a conflict because the user was created concurrently, the user is looked up
+// again and updated.
+func (c *Client) UpsertUser(ctx context.Context, user User) (upsertResponse UpsertUserResponse, userErrorResponse UserErrorResponse, err error) {
+	existing, userErrorResponse, err := c.GetUserByName(ctx, user.UserName)
+	switch {
+	case err == nil && userErrorResponse.Status == "":
+		upsertResponse.UserResponse, userErrorResponse, err = c.UpdateUser(ctx, existing.ID, user)
+		return upsertResponse, userErrorResponse, err
+	case !errors.Is(err, ErrUserNotFound):
+		return upsertResponse, userErrorResponse, err
+	}
+
+	upsertResponse.UserResponse, userErrorResponse, err = c.CreateUser(ctx, user)
+	if !errors.Is(err, ErrConflict) {
+		upsertResponse.Created = err == nil && userErrorResponse.Status == "" && !upsertResponse.Recovered
+		return upsertResponse, userErrorResponse, err
+	}
 
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, userPath, userID)
+	existing, userErrorResponse, err = c.GetUserByName(ctx, user.UserName)
+	if err != nil || userErrorResponse.Status != "" {
+		return upsertResponse, userErrorResponse, err
+	}
+	upsertResponse.UserResponse, userErrorResponse, err = c.UpdateUser(ctx, existing.ID, user)
+	return upsertResponse, userErrorResponse, err
+}
+
+// PatchUser applies the given patch operations to a user with a SCIM PATCH request, leaving all other attributes of
+// the user untouched.
+func (c *Client) PatchUser(ctx context.Context, userID string, ops []PatchOperation) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+
+	fullUrl := c.resourceURL(userPath, userID)
+	//Encode the data
+	patchRequest := PatchRequest{
+		Operations: ops,
+	}
+	patchRequest.fill_defaults()
+	if err := patchRequest.validate(); err != nil {
+		return userResponse, userErrorResponse, err
+	}
+	patchBody, _ := json.Marshal(patchRequest)
+	requestBody := bytes.NewBuffer(patchBody)
 
-	req, err := http.NewRequest("DELETE", fullUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, fullUrl, requestBody)
 	if err != nil {
-		return err
+		return userResponse, userErrorResponse, err
 	}
 
-	_, err = c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
+	}
+	if err := json.Unmarshal(resp, &userResponse); err != nil {
+		return userResponse, userErrorResponse, err
+	}
+	userResponse.ETag = etag(header, userResponse.Meta.Version)
+	if isErrorResponse(userResponse.Schemas) {
+		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
+			return userResponse, userErrorResponse, err
+		}
+	}
+
+	return userResponse, userErrorResponse, nil
+}
+
+// SetPrimaryEmail marks the given address as the primary email of a user and unmarks the others. The emails of the
+// user are read first and sent back complete in a single PATCH, so no other address is dropped. If the user has no
+// such address, an error wrapping ErrNotFound is returned and nothing is changed. Addresses are compared
+// case-insensitively.
+func (c *Client) SetPrimaryEmail(ctx context.Context, userID string, email string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	user, userErrorResponse, err := c.GetUserByID(ctx, userID)
+	if err != nil || userErrorResponse.Status != "" {
+		return userResponse, userErrorResponse, err
+	}
+
+	found := false
+	emails := make([]Email, len(user.Emails))
+	for i, e := range user.Emails {
+		primary := !found && strings.EqualFold(e.Value, email)
+		found = found || primary
+		emails[i] = Email{Primary: primary, Value: e.Value, Type: e.Type}
+	}
+	if !found {
+		return userResponse, userErrorResponse, fmt.Errorf("email %q of user %q: %w", email, userID, ErrNotFound)
+	}
+	return c.PatchUser(ctx, userID, []PatchOperation{
+		ReplaceOperation("emails", emails),
+	})
+}
+
+// ActivateUser sets the active flag of a user to true with a targeted PATCH and returns the updated user.
+func (c *Client) ActivateUser(ctx context.Context, userID string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.setUserActive(ctx, userID, true)
+}
+
+// DeactivateUser sets the active flag of a user to false with a targeted PATCH and returns the updated user.
+func (c *Client) DeactivateUser(ctx context.Context, userID string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.setUserActive(ctx, userID, false)
+}
+
+func (c *Client) setUserActive(ctx context.Context, userID string, active bool) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	return c.PatchUser(ctx, userID, []PatchOperation{
+		ReplaceOperation("active", active),
+	})
+}
+
+// DeleteUser deletes the user with the given ID. An empty 204 No Content response counts as success.
+func (c *Client) DeleteUser(ctx context.Context, userID string) (err error) {
+	return c.doDelete(ctx, c.resourceURL(userPath, userID))
+}
+
+// DeleteUserIfExists deletes a user like DeleteUser, but treats a user that is already gone as a successful no-op.
+func (c *Client) DeleteUserIfExists(ctx context.Context, userID string) (err error) {
+	if err := c.DeleteUser(ctx, userID); err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
 	return nil
 }
 
+// DeleteUserByName looks up the user with the given userName and deletes it. If no user matches, an error wrapping
+// ErrNotFound is returned.
+func (c *Client) DeleteUserByName(ctx context.Context, userName string) (err error) {
+	user, _, err := c.GetUserByName(ctx, userName)
+	if err != nil {
+		return err
+	}
+	return c.DeleteUser(ctx, user.ID)
+}
+
 type UserType int64
 
 const (
@@ -295,32 +733,65 @@ func (u UserType) String() string {
 	return "unknown"
 }
 
+// valid reports whether u is one of the user types known to New Relic.
+func (u UserType) valid() bool {
+	return u >= Full && u <= Basic
+}
+
+// ParseUserType converts a user type name into a UserType. It accepts the names returned by UserType.String, such as
+// "Full User", as well as the short forms "full", "core" and "basic", ignoring case.
+func ParseUserType(s string) (UserType, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for _, u := range []UserType{Full, Core, Basic} {
+		long := strings.ToLower(u.String())
+		if name == long || name == strings.TrimSuffix(long, " user") {
+			return u, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid user type %q", s)
+}
+
 func (c *Client) ChangeUserType(ctx context.Context, userID string, userType UserType) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
 
-	fullUrl := fmt.Sprintf("%s%s/%s", c.BaseUrl, userPath, userID)
+	if !userType.valid() {
+		return userResponse, userErrorResponse, fmt.Errorf("invalid user type %d", userType)
+	}
+	return c.ChangeUserTypeRaw(ctx, userID, userType.String())
+}
+
+// ChangeUserTypeRaw sets the nrUserType of a user to the given value as is, for user types the UserType constants do
+// not cover, such as ones New Relic adds or renames later. The values known to be accepted are "Full User",
+// "Core User" and "Basic User", the names of Full, Core and Basic; prefer ChangeUserType for those.
+func (c *Client) ChangeUserTypeRaw(ctx context.Context, userID string, nrUserType string) (userResponse UserResponse, userErrorResponse UserErrorResponse, err error) {
+	if strings.TrimSpace(nrUserType) == "" {
+		return userResponse, userErrorResponse, errors.New("invalid user type: empty nrUserType")
+	}
+
+	fullUrl := c.resourceURL(userPath, userID)
 	userTypeBody := UserTypeBody{
 		UrnIetfParamsScimSchemasExtensionNewrelic21User: struct {
 			NrUserType string "json:\"nrUserType\""
-		}{NrUserType: userType.String()},
+		}{NrUserType: nrUserType},
 	}
 	//Encode the data
 	userTypeBody.fill_defaults()
 	putBody, _ := json.Marshal(userTypeBody)
 	responseBody := bytes.NewBuffer(putBody)
 
-	req, err := http.NewRequest("PUT", fullUrl, responseBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, fullUrl, responseBody)
 	if err != nil {
 		return userResponse, userErrorResponse, err
 	}
 
-	resp, err := c.doRequest(req)
+	resp, header, err := c.doRequestWithHeader(req)
 	if err != nil {
-		return userResponse, userErrorResponse, err
+		return userResponse, UserErrorResponse(errorResponseOf(err)), err
 	}
 	if err := json.Unmarshal(resp, &userResponse); err != nil {
 		return userResponse, userErrorResponse, err
 	}
-	if userResponse.Schemas[0] == "urn:ietf:params:scim:api:messages:2.0:Error" {
+	userResponse.ETag = etag(header, userResponse.Meta.Version)
+	if isErrorResponse(userResponse.Schemas) {
 		if err := json.Unmarshal(resp, &userErrorResponse); err != nil {
 			return userResponse, userErrorResponse, err
 		}
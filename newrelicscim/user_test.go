@@ -0,0 +1,105 @@
+package newrelicscim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUserTypeBodyJSON(t *testing.T) {
+	body := UserTypeBody{}
+	body.UrnIetfParamsScimSchemasExtensionNewrelic21User.NrUserType = "Full User"
+	body.fill_defaults()
+
+	got, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	want := `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User","urn:ietf:params:scim:schemas:extension:newrelic:2.1:User"],` +
+		`"urn:ietf:params:scim:schemas:extension:newrelic:2.1:User":{"nrUserType":"Full User"}}`
+	if string(got) != want {
+		t.Errorf("json.Marshal(UserTypeBody) =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUserTypeBodySchemaVersion(t *testing.T) {
+	body := UserTypeBody{}
+	body.fill_defaults()
+
+	field, _ := reflect.TypeOf(body).FieldByName("UrnIetfParamsScimSchemasExtensionNewrelic21User")
+	key := field.Tag.Get("json")
+	if len(body.Schemas) != 2 || body.Schemas[1] != key {
+		t.Errorf("schemas = %q, want the extension key %q as the second schema", body.Schemas, key)
+	}
+
+	got, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if _, ok := decoded[newRelicUserSchema]; !ok {
+		t.Errorf("marshalled body %s has no %q key", got, newRelicUserSchema)
+	}
+}
+
+func TestUpdateUserLeavesActiveUnset(t *testing.T) {
+	var sent map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"1","active":false}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	user := User{UserName: "john.doe@example.com", Emails: []Email{{Value: "john.doe@example.com", Primary: true}}}
+
+	if _, err := Result(c.UpdateUser(context.Background(), "1", user)); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if active, ok := sent["active"]; ok {
+		t.Errorf("UpdateUser sent active %v for a user without Active set, want it left out", active)
+	}
+
+	if _, err := Result(c.CreateUser(context.Background(), user)); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if active := sent["active"]; active != true {
+		t.Errorf("CreateUser sent active %v, want the default true", active)
+	}
+}
+
+func TestCreateUserIfNoneMatchExisting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "*" {
+			t.Errorf("If-None-Match = %q, want *", r.Header.Get("If-None-Match"))
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+		w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"412","detail":"user exists"}`))
+	}))
+	defer srv.Close()
+	c := NewClient("token", WithBaseURL(srv.URL))
+	user := User{UserName: "john.doe@example.com", Emails: []Email{{Value: "john.doe@example.com", Primary: true}}}
+
+	_, err := Result(c.CreateUserIfNoneMatch(context.Background(), user))
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("errors.Is(%v, ErrConflict) = false, want true", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed || apiErr.Detail != "user exists" {
+		t.Errorf("errors.As(%v, *APIError) = %+v, want the 412 response", err, apiErr)
+	}
+	var existsErr *UserExistsError
+	if !errors.As(err, &existsErr) || existsErr.UserName != user.UserName {
+		t.Errorf("errors.As(%v, *UserExistsError) = %+v, want the userName", err, existsErr)
+	}
+}